@@ -2,26 +2,35 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
+	"go/parser"
+	"go/token"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/samber/lo"
 	"golang.org/x/tools/imports"
 
 	"github.com/arkbriar/ctrlkit/ctrlkit/pkg/gen"
+	"github.com/arkbriar/ctrlkit/ctrlkit/pkg/lsp"
 )
 
 var (
 	boilerplateFile string
-	targetFile      string
+	targets         []string
 	outputPath      string
 	showHelp        bool
 	verbose         bool
 	ctrlKitPackage  string
+	jsonErrors      bool
+	generateFake    bool
+	verifyOnly      bool
 )
 
 func init() {
@@ -30,6 +39,26 @@ func init() {
 	flag.BoolVar(&showHelp, "h", false, "show help")
 	flag.BoolVar(&verbose, "v", false, "verbose")
 	flag.StringVar(&ctrlKitPackage, "p", "", "replace ctrlkit package")
+	flag.BoolVar(&jsonErrors, "json-errors", false, "print parse errors in json")
+	flag.BoolVar(&generateFake, "fake", false, "generate the fake impls for tests into <name>_fake.go (optional)")
+	flag.BoolVar(&verifyOnly, "verify", false, "verify the generated files are up to date instead of writing them")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] <file.cm | dir | dir/... | glob>...
+       %s fmt [-l] [-w] <file.cm | dir | dir/... | glob>...
+       %s lsp
+
+Generates the codes into the output path, or next to the .cm files without one. A single
+file without an output path is generated to the stdout. The package name is inferred
+from the go files in the output directory.
+
+The fmt command formats the .cm files canonically, and the lsp command serves the
+language server of the .cm files over the stdio.
+
+Flags:
+`, filepath.Base(os.Args[0]), filepath.Base(os.Args[0]), filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
 }
 
 func parseFlags() {
@@ -42,14 +71,14 @@ func parseFlags() {
 		flag.Usage()
 		os.Exit(1)
 	}
-	targetFile = flag.Arg(0)
+	targets = flag.Args()
 
 	if len(ctrlKitPackage) > 0 {
 		gen.CtrlKitPackage = ctrlKitPackage
 	}
 }
 
-func parseDoc() *gen.ControllerManagerDocument {
+func parseDoc(targetFile string) *gen.ControllerManagerDocument {
 	f, err := os.Open(targetFile)
 	if err != nil {
 		fmt.Println(err)
@@ -57,9 +86,12 @@ func parseDoc() *gen.ControllerManagerDocument {
 	}
 	defer f.Close()
 
-	doc, err := gen.ParseDoc(bufio.NewReader(f))
+	doc, err := gen.ParseDocWithOptions(bufio.NewReader(f), gen.ParseOptions{
+		FileName:  targetFile,
+		AllErrors: true,
+	})
 	if err != nil {
-		fmt.Println(err)
+		printParseErrors(err)
 		os.Exit(1)
 	}
 
@@ -73,6 +105,27 @@ func parseDoc() *gen.ControllerManagerDocument {
 	return doc
 }
 
+// printParseErrors prints all the parse errors with the hints, or in json if required.
+func printParseErrors(err error) {
+	errs, ok := err.(gen.ParseErrors)
+	if !ok {
+		fmt.Println(err)
+		return
+	}
+
+	if jsonErrors {
+		b, _ := json.MarshalIndent(errs, "", "  ")
+		fmt.Println(string(b))
+		return
+	}
+	for _, e := range errs {
+		fmt.Println(e)
+		if e.Hint != "" {
+			fmt.Printf("\thint: %s\n", e.Hint)
+		}
+	}
+}
+
 func newGoFileName(f string) string {
 	if strings.HasSuffix(f, ".cm") {
 		return f[:len(f)-3] + ".go"
@@ -80,6 +133,11 @@ func newGoFileName(f string) string {
 	return f + ".go"
 }
 
+func newFakeGoFileName(f string) string {
+	goFile := newGoFileName(f)
+	return goFile[:len(goFile)-3] + "_fake.go"
+}
+
 const (
 	goBuildIgnoreComments = `//go:build !ignore_autogenerated
 // +build !ignore_autogenerated
@@ -91,21 +149,108 @@ const (
 `
 )
 
-func main() {
-	parseFlags()
+// expandTargets expands the directories, the "dir/..." patterns and the globs in the targets
+// into the .cm files. It tells if the targets are a single file as well.
+func expandTargets(targets []string) ([]string, bool, error) {
+	var files []string
+	single := len(targets) == 1
+	for _, target := range targets {
+		if dir := strings.TrimSuffix(target, "/..."); dir != target {
+			single = false
+			if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && strings.HasSuffix(path, ".cm") {
+					files = append(files, path)
+				}
+				return nil
+			}); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(target)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(matches) == 0 {
+			return nil, false, fmt.Errorf("no such file or directory: %s", target)
+		}
+		if len(matches) > 1 || matches[0] != filepath.Clean(target) {
+			single = false
+		}
+		for _, match := range matches {
+			fi, err := os.Stat(match)
+			if err != nil {
+				return nil, false, err
+			}
+			if !fi.IsDir() {
+				files = append(files, match)
+				continue
+			}
+			single = false
+			cmFiles, err := filepath.Glob(filepath.Join(match, "*.cm"))
+			if err != nil {
+				return nil, false, err
+			}
+			files = append(files, cmFiles...)
+		}
+	}
+
+	files = lo.Uniq(files)
+	if len(files) == 0 {
+		return nil, false, fmt.Errorf("no .cm files found in %s", strings.Join(targets, " "))
+	}
+	return files, single, nil
+}
 
-	fileName := newGoFileName(filepath.Base(targetFile))
-	packageName := "manager"
-	if len(outputPath) > 0 {
-		packageName = filepath.Base(outputPath)
+// inferPackageName infers the name of the package in the directory from the go files except
+// the tests, or the $GOPACKAGE of go:generate if it's the working directory, or otherwise
+// the name of the directory.
+func inferPackageName(dir string) string {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.PackageClauseOnly)
+	if err == nil && len(pkgs) > 0 {
+		names := lo.Keys(pkgs)
+		sort.Slice(names, func(i, j int) bool {
+			if len(pkgs[names[i]].Files) != len(pkgs[names[j]].Files) {
+				return len(pkgs[names[i]].Files) > len(pkgs[names[j]].Files)
+			}
+			return names[i] < names[j]
+		})
+		return names[0]
 	}
 
-	s, err := gen.GenerateStubCodes(parseDoc(), packageName)
+	absDir, err := filepath.Abs(dir)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return "manager"
+	}
+	if goPackage := os.Getenv("GOPACKAGE"); goPackage != "" {
+		if wd, err := os.Getwd(); err == nil && wd == absDir {
+			return goPackage
+		}
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, filepath.Base(absDir))
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return "manager"
 	}
+	return name
+}
 
+// renderGoFile formats the codes and renders them with the headers into a go file.
+func renderGoFile(s string) []byte {
 	if verbose {
 		fmt.Println("================= UNFORMATTED CODE =================")
 		fmt.Println(s)
@@ -117,54 +262,199 @@ func main() {
 		fmt.Println(fmt.Errorf("format error: %w", err))
 		os.Exit(1)
 	}
-	s = string(formatted)
 
-	var w io.Writer = os.Stdout
-	if len(outputPath) > 0 {
-		f, err := os.OpenFile(filepath.Join(outputPath, fileName), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	buf := &bytes.Buffer{}
+
+	// Write ignore header.
+	buf.WriteString(goBuildIgnoreComments)
+
+	// Write bolierplate first (if provided).
+	if len(boilerplateFile) > 0 {
+		boilerplate, err := os.ReadFile(boilerplateFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		buf.Write(boilerplate)
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString(doNotEditComment)
+	buf.Write(formatted)
+
+	return buf.Bytes()
+}
+
+// generatedFile is a go file generated from a .cm file.
+type generatedFile struct {
+	path    string
+	content []byte
+}
+
+func generateFiles(targetFile string, outputDir string) []generatedFile {
+	packageName := inferPackageName(outputDir)
+	doc := parseDoc(targetFile)
+
+	s, err := gen.GenerateStubCodes(doc, packageName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	files := []generatedFile{
+		{path: filepath.Join(outputDir, newGoFileName(filepath.Base(targetFile))), content: renderGoFile(s)},
+	}
+
+	if generateFake {
+		s, err := gen.GenerateFakeImplCodes(doc, packageName)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
-		defer f.Close()
+		files = append(files, generatedFile{
+			path:    filepath.Join(outputDir, newFakeGoFileName(filepath.Base(targetFile))),
+			content: renderGoFile(s),
+		})
+	}
+
+	return files
+}
+
+// verifyFile tells if the checked-in file is the same as the generated one.
+func verifyFile(f generatedFile) bool {
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: not generated\n", f.path)
+		return false
+	}
+	if !bytes.Equal(content, f.content) {
+		fmt.Printf("%s: out of date\n", f.path)
+		return false
+	}
+	return true
+}
 
-		w = f
+// serveLSP serves the language server over the stdio, where the errors must not be printed
+// to the stdout.
+func serveLSP() {
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	bw := bufio.NewWriter(w)
-	defer bw.Flush()
+}
 
-	// Write ignore header.
-	if _, err := io.WriteString(w, goBuildIgnoreComments); err != nil {
+// formatDocs formats the .cm files like gofmt, which prints the formatted documents to the
+// stdout, or lists or rewrites the files not formatted.
+func formatDocs(args []string) {
+	flagSet := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flagSet.Bool("l", false, "list the files not formatted")
+	write := flagSet.Bool("w", false, "write the formatted documents back to the files")
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "Usage: %s fmt [-l] [-w] <file.cm | dir | dir/... | glob>...\n\nFlags:\n", filepath.Base(os.Args[0]))
+		flagSet.PrintDefaults()
+	}
+	_ = flagSet.Parse(args)
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		os.Exit(1)
+	}
+
+	targetFiles, _, err := expandTargets(flagSet.Args())
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	// Write bolierplate first (if provided).
-	if len(boilerplateFile) > 0 {
-		func() {
-			f, err := os.Open(boilerplateFile)
-			if err != nil {
+	failed := false
+	for _, targetFile := range targetFiles {
+		src, err := os.ReadFile(targetFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		formatted, err := gen.FormatDoc(src, gen.ParseOptions{
+			FileName:  targetFile,
+			AllErrors: true,
+		})
+		if err != nil {
+			printParseErrors(err)
+			failed = true
+			continue
+		}
+
+		changed := !bytes.Equal(src, formatted)
+		if *list && changed {
+			fmt.Println(targetFile)
+		}
+		if *write && changed {
+			if err := os.WriteFile(targetFile, formatted, 0644); err != nil {
 				fmt.Println(err)
 				os.Exit(1)
 			}
-			if _, err = io.Copy(w, f); err != nil {
+		}
+		if !*list && !*write {
+			if _, err := os.Stdout.Write(formatted); err != nil {
 				fmt.Println(err)
 				os.Exit(1)
 			}
-		}()
-		if _, err := io.WriteString(w, "\n"); err != nil {
-			fmt.Println(err)
-			os.Exit(1)
 		}
 	}
 
-	if _, err := io.WriteString(w, doNotEditComment); err != nil {
-		fmt.Println(err)
+	if failed {
 		os.Exit(1)
 	}
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lsp":
+			serveLSP()
+			return
+		case "fmt":
+			formatDocs(os.Args[2:])
+			return
+		}
+	}
+
+	parseFlags()
 
-	if _, err := io.WriteString(w, s); err != nil {
+	targetFiles, single, err := expandTargets(targets)
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+
+	upToDate := true
+	for _, targetFile := range targetFiles {
+		outputDir := outputPath
+		if len(outputDir) == 0 {
+			outputDir = filepath.Dir(targetFile)
+		}
+
+		for _, f := range generateFiles(targetFile, outputDir) {
+			switch {
+			case verifyOnly:
+				upToDate = verifyFile(f) && upToDate
+			case single && len(outputPath) == 0:
+				if _, err := os.Stdout.Write(f.content); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			default:
+				if err := os.WriteFile(f.path, f.content, 0644); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			}
+		}
+	}
+
+	if !upToDate {
+		fmt.Println("generated files are out of date, regenerate them with ctrlkit-gen")
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_ExpandTargets(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.cm":          "",
+		"b.cm":          "",
+		"c.go":          "",
+		"sub/d.cm":      "",
+		"sub/deep/e.cm": "",
+	})
+	join := func(names ...string) []string {
+		paths := make([]string, 0, len(names))
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+		return paths
+	}
+
+	testcases := map[string]struct {
+		targets []string
+		files   []string
+		single  bool
+	}{
+		"file": {
+			targets: join("a.cm"),
+			files:   join("a.cm"),
+			single:  true,
+		},
+		"dir": {
+			targets: join(""),
+			files:   join("a.cm", "b.cm"),
+		},
+		"glob": {
+			targets: join("*.cm", "a.cm"),
+			files:   join("a.cm", "b.cm"),
+		},
+		"recursive": {
+			targets: []string{filepath.Join(dir, "sub") + "/..."},
+			files:   join("sub/d.cm", "sub/deep/e.cm"),
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			files, single, err := expandTargets(tc.targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, tc.files) || single != tc.single {
+				t.Fatalf("unexpected expansion: %v, %v", files, single)
+			}
+		})
+	}
+
+	if _, _, err := expandTargets(join("missing.cm")); err == nil {
+		t.Fatal("missing file should fail")
+	}
+	if _, _, err := expandTargets(join("sub/deep/*.go")); err == nil {
+		t.Fatal("no .cm files should fail")
+	}
+}
+
+func Test_InferPackageName(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"controllers/a.go":      "package controller\n",
+		"controllers/b.go":      "package controller\n",
+		"controllers/a_test.go": "package controller_test\n",
+		"my-Manager/x.cm":       "",
+		"1st/x.cm":              "",
+	})
+
+	for sub, expected := range map[string]string{
+		"controllers": "controller",
+		"my-Manager":  "mymanager",
+		"1st":         "manager",
+		"missing":     "missing",
+	} {
+		if name := inferPackageName(filepath.Join(dir, sub)); name != expected {
+			t.Fatalf("package name of %s should be %s, but is %s", sub, expected, name)
+		}
+	}
+}
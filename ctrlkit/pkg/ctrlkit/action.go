@@ -13,3 +13,12 @@ type ReconcileAction interface {
 	Description() string
 	Run(ctx context.Context) (ctrl.Result, error)
 }
+
+// Action is the action returned by the generated controller managers. It's an alias of
+// ReconcileAction so that it could be organized with any of the combinators.
+type Action = ReconcileAction
+
+// NewAction returns a new action with the given description and function.
+func NewAction(description string, f func(context.Context) (ctrl.Result, error)) Action {
+	return WrapAction(description, f)
+}
@@ -0,0 +1,150 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// serverManagedMetadataFields are the fields of metadata managed by the server, which must
+// not be included in an apply configuration.
+var serverManagedMetadataFields = []string{
+	"creationTimestamp",
+	"deletionGracePeriodSeconds",
+	"deletionTimestamp",
+	"generation",
+	"managedFields",
+	"resourceVersion",
+	"selfLink",
+	"uid",
+}
+
+// ToApplyObject converts the desired object into an apply configuration for server-side
+// apply. The apiVersion and kind are set from the scheme, the fields managed by the server
+// and the status are dropped, and a controller reference to the owner is set if the owner
+// isn't nil. The namespace is kept as it is. The desired object isn't modified.
+func ToApplyObject(desired, owner client.Object, scheme *runtime.Scheme) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(desired, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get gvk: %w", err)
+	}
+
+	obj := desired.DeepCopyObject().(client.Object)
+	if owner != nil {
+		if err := controllerutil.SetControllerReference(owner, obj, scheme); err != nil {
+			return nil, fmt.Errorf("unable to set controller reference: %w", err)
+		}
+	}
+
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert object: %w", err)
+	}
+	delete(u, "status")
+	if metadata, ok := u["metadata"].(map[string]interface{}); ok {
+		for _, field := range serverManagedMetadataFields {
+			delete(metadata, field)
+		}
+	}
+
+	applied := &unstructured.Unstructured{Object: u}
+	applied.SetGroupVersionKind(gvk)
+	return applied, nil
+}
+
+// ApplyOwnedObject applies the desired object with a controller reference to the owner by
+// server-side apply with the field manager. The ownership of the fields managed by others
+// is taken over if force is true. Otherwise, the conflicts are reported as an
+// *ApplyConflictError. The object is in the namespace of the owner by default, unless it's
+// cluster-scoped. The desired object isn't modified.
+func ApplyOwnedObject(ctx context.Context, c client.Client, owner, desired client.Object, fieldManager string, force bool) error {
+	desired = desired.DeepCopyObject().(client.Object)
+	if err := defaultNamespace(c, owner, desired); err != nil {
+		return err
+	}
+	obj, err := ToApplyObject(desired, owner, c.Scheme())
+	if err != nil {
+		return err
+	}
+
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if force {
+		opts = append(opts, client.ForceOwnership)
+	}
+	if err := c.Patch(ctx, obj, client.Apply, opts...); err != nil {
+		return ReportApplyConflicts(err)
+	}
+	return nil
+}
+
+// FieldManagerConflict is a conflict with another field manager on a field.
+type FieldManagerConflict struct {
+	Manager string
+	Field   string
+}
+
+// ApplyConflictError is the error of a server-side apply conflicting with other field
+// managers.
+type ApplyConflictError struct {
+	Conflicts []FieldManagerConflict
+	Err       error
+}
+
+func (e *ApplyConflictError) Error() string {
+	conflicts := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		conflicts = append(conflicts, fmt.Sprintf("%s (%s)", c.Manager, c.Field))
+	}
+	return "apply conflicts with field managers: " + strings.Join(conflicts, ", ")
+}
+
+func (e *ApplyConflictError) Unwrap() error {
+	return e.Err
+}
+
+// managerOfConflict parses the manager from the message of a conflict cause, e.g.,
+// `conflict with "kubectl" using v1: .data.a`.
+func managerOfConflict(message string) string {
+	start := strings.Index(message, "\"")
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(message[start+1:], "\"")
+	if end < 0 {
+		return ""
+	}
+	return message[start+1 : start+1+end]
+}
+
+// ReportApplyConflicts returns an *ApplyConflictError reporting the conflicting field
+// managers if the error is a conflict of server-side apply. Otherwise, the error is
+// returned as it is.
+func ReportApplyConflicts(err error) error {
+	var statusErr *apierrors.StatusError
+	if !apierrors.IsConflict(err) || !errors.As(err, &statusErr) || statusErr.ErrStatus.Details == nil {
+		return err
+	}
+
+	var conflicts []FieldManagerConflict
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			conflicts = append(conflicts, FieldManagerConflict{
+				Manager: managerOfConflict(cause.Message),
+				Field:   cause.Field,
+			})
+		}
+	}
+	if len(conflicts) == 0 {
+		return err
+	}
+	return &ApplyConflictError{Conflicts: conflicts, Err: err}
+}
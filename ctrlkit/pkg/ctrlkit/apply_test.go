@@ -0,0 +1,162 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// applyClient simulates the field ownership of server-side apply on the data of ConfigMaps,
+// which isn't supported by the fake client. The last object applied is kept.
+type applyClient struct {
+	client.Client
+	owners  map[string]string
+	applied *unstructured.Unstructured
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	patchOptions := &client.PatchOptions{}
+	patchOptions.ApplyOptions(opts)
+	force := patchOptions.Force != nil && *patchOptions.Force
+
+	data, _, _ := unstructured.NestedStringMap(obj.(*unstructured.Unstructured).Object, "data")
+	var causes []metav1.StatusCause
+	for k := range data {
+		field := ".data." + k
+		if owner, ok := c.owners[field]; ok && owner != patchOptions.FieldManager && !force {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: fmt.Sprintf("conflict with %q using v1: %s", owner, field),
+				Field:   field,
+			})
+		}
+	}
+	if len(causes) > 0 {
+		return apierrors.NewApplyConflict(causes, fmt.Sprintf("Apply failed with %d conflicts", len(causes)))
+	}
+	for k := range data {
+		c.owners[".data."+k] = patchOptions.FieldManager
+	}
+	c.applied = obj.(*unstructured.Unstructured)
+	return nil
+}
+
+func Test_ToApplyObject(t *testing.T) {
+	owner := newTestOwner()
+	desired := newDesiredConfigMap(map[string]string{"a": "1"})
+	desired.Namespace = owner.Namespace
+	desired.ResourceVersion = "1"
+	desired.UID = "uid"
+
+	c := fake.NewClientBuilder().Build()
+	obj, err := ToApplyObject(desired, owner, c.Scheme())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.GetAPIVersion() != "v1" || obj.GetKind() != "ConfigMap" {
+		t.Fatal("gvk is not set")
+	}
+	if obj.GetResourceVersion() != "" || obj.GetUID() != "" {
+		t.Fatal("fields managed by server should be dropped")
+	}
+	if !ValidateOwnership(obj, owner) {
+		t.Fatal("controller reference is not set")
+	}
+	if len(desired.OwnerReferences) != 0 {
+		t.Fatal("desired object should not be modified")
+	}
+}
+
+func Test_ReportApplyConflicts(t *testing.T) {
+	c := &applyClient{
+		Client: fake.NewClientBuilder().Build(),
+		owners: map[string]string{".data.a": "kubectl"},
+	}
+	desired := newDesiredConfigMap(map[string]string{"a": "1"})
+	desired.Namespace = "default"
+	obj, err := ToApplyObject(desired, newTestOwner(), c.Scheme())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ReportApplyConflicts(c.Patch(context.Background(), obj, client.Apply, client.FieldOwner("ctrlkit")))
+	var conflictErr *ApplyConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("conflicts should be reported: %v", err)
+	}
+	if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0] != (FieldManagerConflict{Manager: "kubectl", Field: ".data.a"}) {
+		t.Fatalf("conflicts are not correct: %v", conflictErr.Conflicts)
+	}
+	if !apierrors.IsConflict(err) || err.Error() != "apply conflicts with field managers: kubectl (.data.a)" {
+		t.Fatalf("error is not correct: %v", err)
+	}
+
+	// Take over the fields by force.
+	err = c.Patch(context.Background(), obj, client.Apply, client.FieldOwner("ctrlkit"), client.ForceOwnership)
+	if err != nil || c.owners[".data.a"] != "ctrlkit" {
+		t.Fatal("fields should be taken over by force")
+	}
+
+	// Other errors are kept.
+	errNotFound := apierrors.NewNotFound(corev1.Resource("configmaps"), "test")
+	if ReportApplyConflicts(errNotFound) != error(errNotFound) {
+		t.Fatal("other errors should be returned as they are")
+	}
+}
+
+func Test_ApplyOwnedObject(t *testing.T) {
+	c := &applyClient{
+		Client: fake.NewClientBuilder().Build(),
+		owners: map[string]string{".data.a": "kubectl"},
+	}
+	desired := newDesiredConfigMap(map[string]string{"a": "1"})
+
+	// Conflicts are reported without force.
+	err := ApplyOwnedObject(context.Background(), c, newTestOwner(), desired, "ctrlkit", false)
+	var conflictErr *ApplyConflictError
+	if !errors.As(err, &conflictErr) || conflictErr.Conflicts[0].Manager != "kubectl" {
+		t.Fatalf("conflicts should be reported: %v", err)
+	}
+	if c.owners[".data.a"] != "kubectl" {
+		t.Fatal("fields should not be taken over without force")
+	}
+
+	// Take over the fields by force.
+	if err := ApplyOwnedObject(context.Background(), c, newTestOwner(), desired, "ctrlkit", true); err != nil {
+		t.Fatal(err)
+	}
+	if c.owners[".data.a"] != "ctrlkit" {
+		t.Fatal("fields should be taken over by force")
+	}
+}
+
+func Test_ApplyOwnedObject_ClusterScoped(t *testing.T) {
+	c := &applyClient{
+		Client: fake.NewClientBuilder().WithRESTMapper(newTestRESTMapper()).Build(),
+		owners: map[string]string{},
+	}
+
+	// Cluster-scoped objects are not put into the namespace of the owner.
+	if err := ApplyOwnedObject(context.Background(), c, newTestNode(), newDesiredPV(), "ctrlkit", true); err != nil {
+		t.Fatal(err)
+	}
+	if c.applied.GetNamespace() != "" {
+		t.Fatalf("cluster-scoped object should have no namespace: %s", c.applied.GetNamespace())
+	}
+	if err := ApplyOwnedObject(context.Background(), c, newTestOwner(), newDesiredPV(), "ctrlkit", true); err == nil {
+		t.Fatal("cluster-scoped object can't be owned by a namespaced owner")
+	}
+}
@@ -0,0 +1,262 @@
+package ctrlkit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Types of the conditions managed by ConditionManager.
+const (
+	ConditionReady       = "Ready"
+	ConditionProgressing = "Progressing"
+	ConditionDegraded    = "Degraded"
+)
+
+// ReasonReconciled is the reason of the conditions when all actions succeed.
+const ReasonReconciled = "Reconciled"
+
+// ConditionReason derives the reason of a condition from the action name and the outcome,
+// e.g., "SyncJobsFailed" when the action SyncJobs fails, and "SyncJobsRequeued" when it
+// requires a requeue.
+func ConditionReason(action, outcome string) string {
+	var sb strings.Builder
+	for _, r := range action {
+		if r < 0x80 && (r == '_' || ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')) {
+			sb.WriteRune(r)
+		}
+	}
+	reason := sb.String()
+	if reason == "" || !('a' <= reason[0] && reason[0] <= 'z' || 'A' <= reason[0] && reason[0] <= 'Z') {
+		reason = "Action" + reason
+	}
+
+	switch outcome {
+	case OutcomeError:
+		return reason + "Failed"
+	case OutcomeRequeue, OutcomeRequeueAfter:
+		return reason + "Requeued"
+	default:
+		return reason + "Succeeded"
+	}
+}
+
+type actionOutcome struct {
+	action string
+	result ctrl.Result
+	err    error
+}
+
+// DefaultStatusRetryPolicy is the policy used by ConditionManager to retry the status
+// update on conflicts.
+var DefaultStatusRetryPolicy = RetryPolicy{
+	Backoff:     JitteredBackoff(ExponentialBackoff(10*time.Millisecond, 2, time.Second), 0.1),
+	MaxAttempts: 5,
+	Retriable:   apierrors.IsConflict,
+}
+
+// ConditionManager maps the outcomes of the actions onto the Ready, Progressing and Degraded
+// conditions in the status of the target object, and updates the status once at the end of
+// the reconcile if it's changed. It's an ActionHook, so the outcomes of the actions of the
+// generated managers could be observed by passing it with the WithActionHook option.
+type ConditionManager struct {
+	client     client.Client
+	obj        client.Object
+	conditions *[]metav1.Condition
+	original   runtime.Object
+
+	// RetryPolicy is the policy to retry the status update on conflicts.
+	RetryPolicy RetryPolicy
+
+	mu       sync.Mutex
+	outcomes []actionOutcome
+}
+
+func (m *ConditionManager) PreRun(ctx context.Context, logger logr.Logger, action string, states map[string]runtime.Object) {
+}
+
+func (m *ConditionManager) PostRun(ctx context.Context, logger logr.Logger, action string, result ctrl.Result, err error) {
+	m.Observe(action, result, err)
+}
+
+// Observe records the result and error of the action. Exits are ignored.
+func (m *ConditionManager) Observe(action string, result ctrl.Result, err error) {
+	if err == ErrExit {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.outcomes = append(m.outcomes, actionOutcome{action: action, result: result, err: err})
+}
+
+func (m *ConditionManager) computeConditions() []metav1.Condition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var failed, requeued []actionOutcome
+	for _, o := range m.outcomes {
+		switch OutcomeOf(o.result, o.err) {
+		case OutcomeError:
+			failed = append(failed, o)
+		case OutcomeRequeue, OutcomeRequeueAfter:
+			requeued = append(requeued, o)
+		}
+	}
+
+	ready := metav1.Condition{Type: ConditionReady, Status: metav1.ConditionTrue, Reason: ReasonReconciled}
+	progressing := metav1.Condition{Type: ConditionProgressing, Status: metav1.ConditionFalse, Reason: ReasonReconciled}
+	degraded := metav1.Condition{Type: ConditionDegraded, Status: metav1.ConditionFalse, Reason: ReasonReconciled}
+
+	if len(requeued) > 0 {
+		messages := make([]string, 0, len(requeued))
+		for _, o := range requeued {
+			if o.result.RequeueAfter > 0 {
+				messages = append(messages, fmt.Sprintf("action %s requires requeue after %s", o.action, o.result.RequeueAfter))
+			} else {
+				messages = append(messages, fmt.Sprintf("action %s requires requeue", o.action))
+			}
+		}
+		reason := ConditionReason(requeued[0].action, OutcomeRequeue)
+		progressing.Status, progressing.Reason, progressing.Message = metav1.ConditionTrue, reason, strings.Join(messages, "; ")
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, reason, progressing.Message
+	}
+
+	if len(failed) > 0 {
+		messages := make([]string, 0, len(failed))
+		for _, o := range failed {
+			messages = append(messages, fmt.Sprintf("action %s failed: %s", o.action, o.err))
+		}
+		reason := ConditionReason(failed[0].action, OutcomeError)
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, reason, strings.Join(messages, "; ")
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, reason, degraded.Message
+	}
+
+	return []metav1.Condition{ready, progressing, degraded}
+}
+
+// ApplyConditions sets the conditions computed from the outcomes observed so far onto the
+// status of the target object. The last transition times are kept if the statuses of the
+// conditions are unchanged.
+func (m *ConditionManager) ApplyConditions() {
+	for _, cond := range m.computeConditions() {
+		cond.ObservedGeneration = m.obj.GetGeneration()
+		meta.SetStatusCondition(m.conditions, cond)
+	}
+}
+
+func statusOf(obj runtime.Object) (interface{}, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return u["status"], nil
+}
+
+func setStatus(obj runtime.Object, status interface{}) error {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	u["status"] = status
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u, obj)
+}
+
+// IsStatusUpdated tells if the status of the target object has been changed since the
+// manager was created or the status was last updated.
+func (m *ConditionManager) IsStatusUpdated() (bool, error) {
+	original, err := statusOf(m.original)
+	if err != nil {
+		return false, err
+	}
+	current, err := statusOf(m.obj)
+	if err != nil {
+		return false, err
+	}
+	return !equality.Semantic.DeepEqual(original, current), nil
+}
+
+func (m *ConditionManager) updateStatus(ctx context.Context) (ctrl.Result, error) {
+	m.ApplyConditions()
+
+	if updated, err := m.IsStatusUpdated(); err != nil {
+		return RequeueIfError(fmt.Errorf("unable to diff status: %w", err))
+	} else if !updated {
+		return NoRequeue()
+	}
+
+	desired, err := statusOf(m.obj)
+	if err != nil {
+		return RequeueIfError(fmt.Errorf("unable to diff status: %w", err))
+	}
+
+	attempt := 0
+	update := WrapAction("UpdateStatusOnce", func(ctx context.Context) (ctrl.Result, error) {
+		target := m.obj
+
+		// Get the latest object and reapply the status on conflicts.
+		if attempt > 0 {
+			target = reflect.New(reflect.TypeOf(m.obj).Elem()).Interface().(client.Object)
+			if err := m.client.Get(ctx, client.ObjectKeyFromObject(m.obj), target); apierrors.IsNotFound(err) {
+				return NoRequeue()
+			} else if err != nil {
+				return RequeueIfError(fmt.Errorf("unable to get object: %w", err))
+			}
+			if err := setStatus(target, desired); err != nil {
+				return RequeueIfError(fmt.Errorf("unable to set status: %w", err))
+			}
+		}
+		attempt++
+
+		// The object may be gone after its finalizers are removed, no status to update.
+		if err := m.client.Status().Update(ctx, target); apierrors.IsNotFound(err) {
+			return NoRequeue()
+		} else if err != nil {
+			return RequeueIfError(fmt.Errorf("unable to update status: %w", err))
+		}
+		if target != m.obj {
+			reflect.ValueOf(m.obj).Elem().Set(reflect.ValueOf(target).Elem())
+		}
+		return NoRequeue()
+	})
+
+	if result, err := Retry(m.RetryPolicy, update).Run(ctx); NeedsRequeue(result, err) {
+		return result, err
+	}
+	m.original = m.obj.DeepCopyObject()
+	return NoRequeue()
+}
+
+// UpdateStatus returns an action that applies the conditions and updates the status of the
+// target object if it's changed. Conflicts are retried with the latest object following the
+// RetryPolicy. It's expected to run once at the end of the reconcile. Nothing is updated if
+// the object is not found, e.g., when it's deleted after its finalizers are removed.
+func (m *ConditionManager) UpdateStatus() ReconcileAction {
+	return WrapAction("UpdateStatus", m.updateStatus)
+}
+
+// NewConditionManager returns a ConditionManager of the target object. The conditions must
+// point to the conditions in the status of the object. The status of the object is copied
+// at creation to tell if it's changed later.
+func NewConditionManager(c client.Client, obj client.Object, conditions *[]metav1.Condition) *ConditionManager {
+	return &ConditionManager{
+		client:      c,
+		obj:         obj,
+		conditions:  conditions,
+		original:    obj.DeepCopyObject(),
+		RetryPolicy: DefaultStatusRetryPolicy,
+	}
+}
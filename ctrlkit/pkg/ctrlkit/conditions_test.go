@@ -0,0 +1,149 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestPDB() *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test",
+			Namespace:  "default",
+			Generation: 2,
+		},
+	}
+}
+
+func getTestPDB(t *testing.T, c client.Client) *policyv1.PodDisruptionBudget {
+	var pdb policyv1.PodDisruptionBudget
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "test"}, &pdb); err != nil {
+		t.Fatal(err)
+	}
+	return &pdb
+}
+
+func Test_ConditionReason(t *testing.T) {
+	if ConditionReason("SyncJobs", OutcomeError) != "SyncJobsFailed" {
+		t.Fatal("reason of failure is not correct")
+	}
+	if ConditionReason("SyncJobs", OutcomeRequeueAfter) != "SyncJobsRequeued" {
+		t.Fatal("reason of requeue is not correct")
+	}
+	if ConditionReason("Sync(jobs)", OutcomeError) != "SyncjobsFailed" {
+		t.Fatal("invalid characters should be dropped")
+	}
+	if ConditionReason("1st", OutcomeError) != "Action1stFailed" {
+		t.Fatal("reason should start with a letter")
+	}
+}
+
+func Test_ConditionManager_Conditions(t *testing.T) {
+	pdb := newTestPDB()
+	m := NewConditionManager(nil, pdb, &pdb.Status.Conditions)
+
+	m.PostRun(context.Background(), logr.Discard(), "A", ctrl.Result{}, nil)
+	m.PostRun(context.Background(), logr.Discard(), "B", ctrl.Result{}, ErrExit)
+	m.ApplyConditions()
+	if !meta.IsStatusConditionTrue(pdb.Status.Conditions, ConditionReady) ||
+		!meta.IsStatusConditionFalse(pdb.Status.Conditions, ConditionProgressing) ||
+		!meta.IsStatusConditionFalse(pdb.Status.Conditions, ConditionDegraded) {
+		t.Fatalf("conditions are not correct: %v", pdb.Status.Conditions)
+	}
+	if meta.FindStatusCondition(pdb.Status.Conditions, ConditionReady).ObservedGeneration != 2 {
+		t.Fatal("observed generation is not set")
+	}
+
+	m.Observe("B", ctrl.Result{RequeueAfter: time.Second}, nil)
+	m.Observe("C", ctrl.Result{}, errors.New("failed"))
+	m.ApplyConditions()
+	ready := meta.FindStatusCondition(pdb.Status.Conditions, ConditionReady)
+	if ready.Status != metav1.ConditionFalse || ready.Reason != "CFailed" {
+		t.Fatalf("ready condition is not correct: %v", ready)
+	}
+	if progressing := meta.FindStatusCondition(pdb.Status.Conditions, ConditionProgressing); progressing.Status != metav1.ConditionTrue ||
+		progressing.Reason != "BRequeued" || progressing.Message != "action B requires requeue after 1s" {
+		t.Fatalf("progressing condition is not correct: %v", progressing)
+	}
+	if degraded := meta.FindStatusCondition(pdb.Status.Conditions, ConditionDegraded); degraded.Status != metav1.ConditionTrue ||
+		degraded.Reason != "CFailed" || degraded.Message != "action C failed: failed" {
+		t.Fatalf("degraded condition is not correct: %v", degraded)
+	}
+}
+
+func Test_ConditionManager_UpdateStatus(t *testing.T) {
+	pdb := newTestPDB()
+	c := fake.NewClientBuilder().WithObjects(pdb).Build()
+	pdb = getTestPDB(t, c)
+
+	m := NewConditionManager(c, pdb, &pdb.Status.Conditions)
+	m.Observe("A", ctrl.Result{}, nil)
+	if _, err := m.UpdateStatus().Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(getTestPDB(t, c).Status.Conditions, ConditionReady) {
+		t.Fatal("status is not updated")
+	}
+
+	// Nothing changed, no more updates.
+	resourceVersion := getTestPDB(t, c).ResourceVersion
+	if _, err := m.UpdateStatus().Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if getTestPDB(t, c).ResourceVersion != resourceVersion {
+		t.Fatal("status should not be updated if unchanged")
+	}
+}
+
+func Test_ConditionManager_UpdateStatusOnConflict(t *testing.T) {
+	pdb := newTestPDB()
+	c := fake.NewClientBuilder().WithObjects(pdb).Build()
+	pdb = getTestPDB(t, c)
+
+	m := NewConditionManager(c, pdb, &pdb.Status.Conditions)
+	m.RetryPolicy.Backoff = ConstantBackoff(0)
+	m.Observe("A", ctrl.Result{}, errors.New("failed"))
+
+	// Update the object behind the manager to make a conflict.
+	latest := getTestPDB(t, c)
+	latest.Labels = map[string]string{"updated": "true"}
+	if err := c.Update(context.Background(), latest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.UpdateStatus().Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	latest = getTestPDB(t, c)
+	if !meta.IsStatusConditionTrue(latest.Status.Conditions, ConditionDegraded) {
+		t.Fatal("status is not updated after conflict")
+	}
+	if latest.Labels["updated"] != "true" || pdb.Labels["updated"] != "true" {
+		t.Fatal("object should be refreshed with the latest one")
+	}
+}
+
+func Test_ConditionManager_UpdateStatusNotFound(t *testing.T) {
+	pdb := newTestPDB()
+	c := fake.NewClientBuilder().WithObjects(pdb).Build()
+	pdb = getTestPDB(t, c)
+	if err := c.Delete(context.Background(), pdb.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewConditionManager(c, pdb, &pdb.Status.Conditions)
+	m.Observe("A", ctrl.Result{}, nil)
+	if result, err := m.UpdateStatus().Run(context.Background()); NeedsRequeue(result, err) {
+		t.Fatalf("deleted object should not be requeued: %v, %v", result, err)
+	}
+}
@@ -0,0 +1,32 @@
+package ctrlkit
+
+import (
+	"context"
+	"fmt"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+type deferredAction struct {
+	inner    ReconcileAction
+	deferred ReconcileAction
+}
+
+func (act *deferredAction) Description() string {
+	return fmt.Sprintf("Defer(%s, %s)", act.inner.Description(), act.deferred.Description())
+}
+
+func (act *deferredAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
+	result, err = runChild(ctx, act.inner)
+	lr, lerr := runChild(ctx, act.deferred)
+	return joinResultAndErr(result, err, lr, lerr)
+}
+
+// Defer runs the deferred action after the action regardless of its result, e.g., to
+// update the status, and joins the results.
+func Defer(act ReconcileAction, deferred ReconcileAction) ReconcileAction {
+	return &deferredAction{inner: act, deferred: deferred}
+}
@@ -0,0 +1,35 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func Test_Defer(t *testing.T) {
+	var deferredRun bool
+	deferred := WrapAction("Deferred", func(ctx context.Context) (ctrl.Result, error) {
+		deferredRun = true
+		return ctrl.Result{RequeueAfter: time.Second}, nil
+	})
+
+	act := Defer(namedAction("A", ctrl.Result{}, ErrExit), deferred)
+	if act.Description() != "Defer(A, Deferred)" {
+		t.Fatal("description of defer is not correct")
+	}
+	result, err := act.Run(context.Background())
+	if !deferredRun {
+		t.Fatal("deferred action should run after exit")
+	}
+	if err != ErrExit || result.RequeueAfter != time.Second {
+		t.Fatalf("results should be joined: %v, %v", result, err)
+	}
+
+	errDeferred := errors.New("deferred")
+	if _, err := Defer(namedAction("A", ctrl.Result{}, nil), namedAction("B", ctrl.Result{}, errDeferred)).Run(context.Background()); err != errDeferred {
+		t.Fatalf("error of deferred action should be returned: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package ctrlkit
+
+import (
+	"context"
+	"fmt"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// IsBeingDeleted reports if the object has a deletion timestamp.
+func IsBeingDeleted(obj client.Object) bool {
+	return !obj.GetDeletionTimestamp().IsZero()
+}
+
+// AddFinalizer adds the finalizer to the object and patches it if it's missing. It reports
+// whether the object is patched. The object is updated in place.
+func AddFinalizer(ctx context.Context, c client.Client, obj client.Object, finalizer string) (bool, error) {
+	if controllerutil.ContainsFinalizer(obj, finalizer) {
+		return false, nil
+	}
+
+	patch := client.MergeFromWithOptions(obj.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+	controllerutil.AddFinalizer(obj, finalizer)
+	if err := c.Patch(ctx, obj, patch); err != nil {
+		return false, fmt.Errorf("unable to add finalizer %s: %w", finalizer, err)
+	}
+	return true, nil
+}
+
+// RemoveFinalizer removes the finalizer from the object and patches it if it's present. It
+// reports whether the object is patched. The object is updated in place.
+func RemoveFinalizer(ctx context.Context, c client.Client, obj client.Object, finalizer string) (bool, error) {
+	if !controllerutil.ContainsFinalizer(obj, finalizer) {
+		return false, nil
+	}
+
+	patch := client.MergeFromWithOptions(obj.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+	controllerutil.RemoveFinalizer(obj, finalizer)
+	if err := c.Patch(ctx, obj, patch); err != nil {
+		return false, fmt.Errorf("unable to remove finalizer %s: %w", finalizer, err)
+	}
+	return true, nil
+}
+
+type finalizerAction struct {
+	client    client.Client
+	obj       client.Object
+	finalizer string
+	cleanup   ReconcileAction
+}
+
+func (act *finalizerAction) Description() string {
+	return fmt.Sprintf("Finalizer(%s, %s)", act.finalizer, act.cleanup.Description())
+}
+
+func (act *finalizerAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
+	// Ensure the finalizer is present when the object is alive.
+	if !IsBeingDeleted(act.obj) {
+		skipSpan(ctx, act.cleanup)
+		if _, err := AddFinalizer(ctx, act.client, act.obj, act.finalizer); err != nil {
+			return RequeueIfError(err)
+		}
+		return NoRequeue()
+	}
+
+	// Nothing to do if the finalizer has been removed.
+	if !controllerutil.ContainsFinalizer(act.obj, act.finalizer) {
+		skipSpan(ctx, act.cleanup)
+		return Exit()
+	}
+
+	// Clean up and remove the finalizer only if the clean up is done.
+	if result, err := runChild(ctx, act.cleanup); NeedsRequeue(result, err) {
+		return result, err
+	}
+	if _, err := RemoveFinalizer(ctx, act.client, act.obj, act.finalizer); err != nil {
+		return RequeueIfError(err)
+	}
+	return Exit()
+}
+
+// Finalizer returns an action managing the finalizer of the object. If the object isn't
+// being deleted, it adds the finalizer when missing and continues. Otherwise, it runs the
+// cleanup action and removes the finalizer after the cleanup succeeds without requeue.
+// It exits the workflow while the object is being deleted, so that the normal reconcile
+// won't proceed.
+func Finalizer(c client.Client, obj client.Object, finalizer string, cleanup ReconcileAction) ReconcileAction {
+	return &finalizerAction{client: c, obj: obj, finalizer: finalizer, cleanup: cleanup}
+}
@@ -0,0 +1,114 @@
+package ctrlkit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const testFinalizer = "ctrlkit.io/test"
+
+func newTestConfigMap(deleting bool, finalizers ...string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test",
+			Namespace:  "default",
+			Finalizers: finalizers,
+		},
+	}
+	if deleting {
+		now := metav1.NewTime(time.Now())
+		cm.DeletionTimestamp = &now
+	}
+	return cm
+}
+
+func getTestConfigMap(t *testing.T, c client.Client) *corev1.ConfigMap {
+	var cm corev1.ConfigMap
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "test"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	return &cm
+}
+
+func Test_AddAndRemoveFinalizer(t *testing.T) {
+	cm := newTestConfigMap(false)
+	c := fake.NewClientBuilder().WithObjects(cm).Build()
+
+	if patched, err := AddFinalizer(context.Background(), c, cm, testFinalizer); err != nil || !patched {
+		t.Fatal("finalizer should be added")
+	}
+	if patched, err := AddFinalizer(context.Background(), c, cm, testFinalizer); err != nil || patched {
+		t.Fatal("finalizer should not be added twice")
+	}
+	if !controllerutil.ContainsFinalizer(getTestConfigMap(t, c), testFinalizer) {
+		t.Fatal("finalizer is not persisted")
+	}
+
+	if patched, err := RemoveFinalizer(context.Background(), c, cm, testFinalizer); err != nil || !patched {
+		t.Fatal("finalizer should be removed")
+	}
+	if controllerutil.ContainsFinalizer(getTestConfigMap(t, c), testFinalizer) {
+		t.Fatal("finalizer is not removed")
+	}
+}
+
+func Test_Finalizer(t *testing.T) {
+	var cleaned int
+	cleanup := WrapAction("CleanUp", func(ctx context.Context) (ctrl.Result, error) {
+		cleaned++
+		return NoRequeue()
+	})
+
+	// Alive, finalizer should be added.
+	cm := newTestConfigMap(false)
+	c := fake.NewClientBuilder().WithObjects(cm).Build()
+	act := Finalizer(c, cm, testFinalizer, cleanup)
+	if act.Description() != "Finalizer(ctrlkit.io/test, CleanUp)" {
+		t.Fatal("description of finalizer is not correct")
+	}
+	result, err := act.Run(context.Background())
+	if NeedsRequeue(result, err) || cleaned != 0 {
+		t.Fatal("finalizer should continue without clean up")
+	}
+	if !controllerutil.ContainsFinalizer(getTestConfigMap(t, c), testFinalizer) {
+		t.Fatal("finalizer is not added")
+	}
+
+	// Being deleted, clean up and remove the finalizer.
+	cm = newTestConfigMap(true, testFinalizer)
+	c = fake.NewClientBuilder().WithObjects(cm).Build()
+	_, err = Finalizer(c, cm, testFinalizer, cleanup).Run(context.Background())
+	if err != ErrExit || cleaned != 1 {
+		t.Fatal("finalizer should clean up and exit")
+	}
+	// The object is gone once the last finalizer is removed.
+	err = c.Get(context.Background(), client.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatal("finalizer is not removed")
+	}
+}
+
+func Test_Finalizer_CleanUpNotDone(t *testing.T) {
+	cm := newTestConfigMap(true, testFinalizer)
+	c := fake.NewClientBuilder().WithObjects(cm).Build()
+	cleanup := WrapAction("CleanUp", func(ctx context.Context) (ctrl.Result, error) {
+		return RequeueAfter(time.Second)
+	})
+
+	result, err := Finalizer(c, cm, testFinalizer, cleanup).Run(context.Background())
+	if err != nil || result.RequeueAfter != time.Second {
+		t.Fatal("result of clean up should be returned")
+	}
+	if !controllerutil.ContainsFinalizer(getTestConfigMap(t, c), testFinalizer) {
+		t.Fatal("finalizer should be kept until clean up is done")
+	}
+}
@@ -2,17 +2,84 @@ package ctrlkit
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
 )
 
+// ActionHook provides hooks around the actions of the controller managers.
+type ActionHook interface {
+	// PreRun is invoked before the action runs, with the states it's going to consume.
+	PreRun(ctx context.Context, logger logr.Logger, action string, states map[string]runtime.Object)
+
+	// PostRun is invoked after the action runs, with the result and error it returns.
+	PostRun(ctx context.Context, logger logr.Logger, action string, result ctrl.Result, err error)
+}
+
+type actionRunKey struct{}
+
+// StartActionRun returns a copy of the context carrying the start time of a run of an action.
+// The generated managers start a run before invoking the hooks, so that the hooks could time
+// the run with the context passed to PostRun, see ActionRunStartTime.
+func StartActionRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, actionRunKey{}, time.Now())
+}
+
+// ActionRunStartTime returns the start time of the run carried on the context, and false if
+// there's none.
+func ActionRunStartTime(ctx context.Context) (time.Time, bool) {
+	start, ok := ctx.Value(actionRunKey{}).(time.Time)
+	return start, ok
+}
+
+// EmptyActionHook implements empty hooks.
+type EmptyActionHook struct {
+}
+
+func (hook *EmptyActionHook) PreRun(ctx context.Context, logger logr.Logger, action string, states map[string]runtime.Object) {
+}
+
+func (hook *EmptyActionHook) PostRun(ctx context.Context, logger logr.Logger, action string, result ctrl.Result, err error) {
+}
+
+type chainedActionHooks []ActionHook
+
+func (hooks chainedActionHooks) PreRun(ctx context.Context, logger logr.Logger, action string, states map[string]runtime.Object) {
+	for _, hook := range hooks {
+		hook.PreRun(ctx, logger, action, states)
+	}
+}
+
+func (hooks chainedActionHooks) PostRun(ctx context.Context, logger logr.Logger, action string, result ctrl.Result, err error) {
+	// Invoke in the reversed order, like the deferred calls.
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].PostRun(ctx, logger, action, result, err)
+	}
+}
+
+// ChainActionHooks chains the hooks into one. PreRun of the hooks are invoked in order and
+// PostRun are invoked in the reversed order.
+func ChainActionHooks(hooks ...ActionHook) ActionHook {
+	if len(hooks) == 1 {
+		return hooks[0]
+	}
+	return chainedActionHooks(hooks)
+}
+
 // CrontollerManagerActionLifeCycleHook provides lifecycle hooks for actions.
+//
+// Deprecated: use ActionHook instead. An existing implementation could be adapted
+// with ActionHookFromLifeCycleHook.
 type CrontollerManagerActionLifeCycleHook interface {
 	BeforeActionRun(action string, ctx context.Context, logger logr.Logger)
 	AfterActionRun(action string, ctx context.Context, logger logr.Logger)
 }
 
 // EmptyCrontollerManagerActionLifeCycleHook implements empty hooks.
+//
+// Deprecated: use EmptyActionHook instead.
 type EmptyCrontollerManagerActionLifeCycleHook struct {
 }
 
@@ -21,3 +88,21 @@ func (hook *EmptyCrontollerManagerActionLifeCycleHook) BeforeActionRun(action st
 
 func (hook *EmptyCrontollerManagerActionLifeCycleHook) AfterActionRun(action string, ctx context.Context, logger logr.Logger) {
 }
+
+type lifeCycleHookAdapter struct {
+	hook CrontollerManagerActionLifeCycleHook
+}
+
+func (a *lifeCycleHookAdapter) PreRun(ctx context.Context, logger logr.Logger, action string, states map[string]runtime.Object) {
+	a.hook.BeforeActionRun(action, ctx, logger)
+}
+
+func (a *lifeCycleHookAdapter) PostRun(ctx context.Context, logger logr.Logger, action string, result ctrl.Result, err error) {
+	a.hook.AfterActionRun(action, ctx, logger)
+}
+
+// ActionHookFromLifeCycleHook adapts the legacy lifecycle hook into an ActionHook. The
+// states, results and errors are dropped since the legacy hook doesn't accept them.
+func ActionHookFromLifeCycleHook(hook CrontollerManagerActionLifeCycleHook) ActionHook {
+	return &lifeCycleHookAdapter{hook: hook}
+}
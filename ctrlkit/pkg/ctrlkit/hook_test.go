@@ -0,0 +1,48 @@
+package ctrlkit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+type recordLifeCycleHook struct {
+	calls []string
+}
+
+func (h *recordLifeCycleHook) BeforeActionRun(action string, ctx context.Context, logger logr.Logger) {
+	h.calls = append(h.calls, "before:"+action)
+}
+
+func (h *recordLifeCycleHook) AfterActionRun(action string, ctx context.Context, logger logr.Logger) {
+	h.calls = append(h.calls, "after:"+action)
+}
+
+func Test_NewAction(t *testing.T) {
+	act := NewAction("Test", func(ctx context.Context) (ctrl.Result, error) {
+		return RequeueImmediately()
+	})
+
+	if act.Description() != "Test" {
+		t.Fatal("description of action is not correct")
+	}
+
+	result, err := act.Run(context.Background())
+	if err != nil || !result.Requeue {
+		t.Fatal("result of action is not correct")
+	}
+}
+
+func Test_ActionHookFromLifeCycleHook(t *testing.T) {
+	legacy := &recordLifeCycleHook{}
+	hook := ActionHookFromLifeCycleHook(legacy)
+
+	hook.PreRun(context.Background(), logr.Discard(), "Test", nil)
+	hook.PostRun(context.Background(), logr.Discard(), "Test", ctrl.Result{}, nil)
+
+	if len(legacy.calls) != 2 || legacy.calls[0] != "before:Test" || legacy.calls[1] != "after:Test" {
+		t.Fatalf("legacy hook not invoked as expected: %v", legacy.calls)
+	}
+}
@@ -1,5 +1,14 @@
 package ctrlkit
 
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
 // If returns the given action if predicate is true, or an Nop otherwise.
 func If(predicate bool, act ReconcileAction) ReconcileAction {
 	if predicate {
@@ -8,3 +17,178 @@ func If(predicate bool, act ReconcileAction) ReconcileAction {
 		return Nop
 	}
 }
+
+// Predicate is a condition evaluated when the action runs.
+type Predicate interface {
+	Evaluate(ctx context.Context) (bool, error)
+}
+
+// PredicateFunc is an anonymous Predicate of the function.
+type PredicateFunc func(ctx context.Context) (bool, error)
+
+func (f PredicateFunc) Evaluate(ctx context.Context) (bool, error) {
+	return f(ctx)
+}
+
+type namedPredicate struct {
+	name string
+	f    PredicateFunc
+}
+
+func (p *namedPredicate) Evaluate(ctx context.Context) (bool, error) {
+	return p.f(ctx)
+}
+
+// Named returns the Predicate of the function with the name, which is shown in the
+// descriptions of the actions evaluating it, e.g., "When(Ready, A, Nop)".
+func Named(name string, f PredicateFunc) Predicate {
+	return &namedPredicate{name: name, f: f}
+}
+
+// describeWithPredicate formats the description of the action with the name of the predicate
+// before the args if it's named.
+func describeWithPredicate(head string, predicate Predicate, args ...string) string {
+	if p, ok := predicate.(*namedPredicate); ok {
+		args = append([]string{p.name}, args...)
+	}
+	return fmt.Sprintf("%s(%s)", head, strings.Join(args, ", "))
+}
+
+func evaluate(ctx context.Context, predicate Predicate) (bool, error) {
+	ok, err := predicate.Evaluate(ctx)
+	if err != nil {
+		return false, fmt.Errorf("unable to evaluate predicate: %w", err)
+	}
+	return ok, nil
+}
+
+type conditionalAction struct {
+	head      string
+	predicate Predicate
+	negate    bool
+	then      ReconcileAction
+	otherwise ReconcileAction
+}
+
+func (act *conditionalAction) Description() string {
+	return describeWithPredicate(act.head, act.predicate, act.then.Description(), act.otherwise.Description())
+}
+
+func (act *conditionalAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
+	ok, err := evaluate(ctx, act.predicate)
+	if err != nil {
+		return RequeueIfError(err)
+	}
+
+	taken, skipped := act.then, act.otherwise
+	if ok == act.negate {
+		taken, skipped = skipped, taken
+	}
+	skipSpan(ctx, skipped)
+
+	return runChild(ctx, taken)
+}
+
+// When returns an action that runs the given action if the predicate evaluates to true
+// at run time, or does nothing otherwise.
+func When(predicate Predicate, act ReconcileAction) ReconcileAction {
+	return &conditionalAction{head: KindWhen, predicate: predicate, then: act, otherwise: Nop}
+}
+
+// Unless returns an action that runs the given action if the predicate evaluates to false
+// at run time, or does nothing otherwise.
+func Unless(predicate Predicate, act ReconcileAction) ReconcileAction {
+	return &conditionalAction{head: KindUnless, predicate: predicate, negate: true, then: act, otherwise: Nop}
+}
+
+// IfElse returns an action that runs the then action if the predicate evaluates to true
+// at run time, or the otherwise action if not.
+func IfElse(predicate Predicate, then, otherwise ReconcileAction) ReconcileAction {
+	return &conditionalAction{head: KindIfElse, predicate: predicate, then: then, otherwise: otherwise}
+}
+
+// SwitchCase is a branch of Switch.
+type SwitchCase struct {
+	// Predicate of the branch. Nil means always true.
+	Predicate Predicate
+
+	// Action to run when the branch is chosen.
+	Action ReconcileAction
+}
+
+func (c *SwitchCase) description() string {
+	if c.Predicate == nil {
+		return fmt.Sprintf("Default(%s)", c.Action.Description())
+	}
+	return describeWithPredicate("Case", c.Predicate, c.Action.Description())
+}
+
+// Case returns a branch of Switch, which is chosen when the predicate evaluates to true.
+func Case(predicate Predicate, act ReconcileAction) SwitchCase {
+	return SwitchCase{Predicate: predicate, Action: act}
+}
+
+// Default returns a branch of Switch, which is always chosen if it's reached.
+func Default(act ReconcileAction) SwitchCase {
+	return SwitchCase{Action: act}
+}
+
+type switchAction struct {
+	cases []SwitchCase
+}
+
+func (act *switchAction) Description() string {
+	buf := &bytes.Buffer{}
+
+	buf.WriteString(KindSwitch)
+	buf.WriteString("(")
+	for i := range act.cases {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(act.cases[i].description())
+	}
+	buf.WriteString(")")
+
+	return buf.String()
+}
+
+func (act *switchAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
+	// Evaluate the predicates in order and run the first branch chosen.
+	for i, c := range act.cases {
+		ok := true
+		if c.Predicate != nil {
+			if ok, err = evaluate(ctx, c.Predicate); err != nil {
+				return RequeueIfError(err)
+			}
+		}
+		if !ok {
+			skipSpan(ctx, c.Action)
+			continue
+		}
+
+		for _, skipped := range act.cases[i+1:] {
+			skipSpan(ctx, skipped.Action)
+		}
+		return runChild(ctx, c.Action)
+	}
+
+	return NoRequeue()
+}
+
+// Switch returns an action that evaluates the predicates of the cases in order at run time,
+// and runs the action of the first case whose predicate evaluates to true. It does nothing
+// if there's none.
+func Switch(cases ...SwitchCase) ReconcileAction {
+	if len(cases) == 0 {
+		panic("must provide cases to switch")
+	}
+
+	return &switchAction{cases: cases}
+}
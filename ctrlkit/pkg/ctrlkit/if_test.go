@@ -0,0 +1,141 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func constPredicate(v bool) Predicate {
+	return PredicateFunc(func(ctx context.Context) (bool, error) {
+		return v, nil
+	})
+}
+
+// runDescription runs the conditional action and returns the description of the branch run.
+func runDescription(t *testing.T, act ReconcileAction) string {
+	recorder := NewTraceRecorder()
+	if _, err := act.Run(WithTracer(context.Background(), recorder)); err != nil {
+		t.Fatal(err)
+	}
+	for _, span := range recorder.Spans()[0].Children {
+		if !span.Skipped {
+			return span.Description
+		}
+	}
+	return ""
+}
+
+func Test_When(t *testing.T) {
+	a := namedAction("A", ctrl.Result{}, nil)
+
+	if When(constPredicate(true), a).Description() != "When(A, Nop)" {
+		t.Fatal("description of when is not correct")
+	}
+	if runDescription(t, When(constPredicate(true), a)) != "A" {
+		t.Fatal("action should run when predicate is true")
+	}
+	if runDescription(t, When(constPredicate(false), a)) != "Nop" {
+		t.Fatal("action should not run when predicate is false")
+	}
+
+	if Unless(constPredicate(true), a).Description() != "Unless(A, Nop)" {
+		t.Fatal("description of unless is not correct")
+	}
+	if runDescription(t, Unless(constPredicate(false), a)) != "A" {
+		t.Fatal("action should run when predicate is false")
+	}
+	if runDescription(t, Unless(constPredicate(true), a)) != "Nop" {
+		t.Fatal("action should not run when predicate is true")
+	}
+}
+
+func constFunc(v bool) PredicateFunc {
+	return func(ctx context.Context) (bool, error) {
+		return v, nil
+	}
+}
+
+func Test_Named(t *testing.T) {
+	a, b := namedAction("A", ctrl.Result{}, nil), namedAction("B", ctrl.Result{}, nil)
+
+	for _, tc := range []struct {
+		act         ReconcileAction
+		description string
+		ran         string
+	}{
+		{When(Named("Ready", constFunc(true)), a), "When(Ready, A, Nop)", "A"},
+		{Unless(Named("Suspended", constFunc(true)), a), "Unless(Suspended, A, Nop)", "Nop"},
+		{IfElse(Named("Ready", constFunc(false)), a, b), "IfElse(Ready, A, B)", "B"},
+		{Switch(Case(Named("Ready", constFunc(false)), a), Case(constPredicate(true), b)), "Switch(Case(Ready, A), Case(B))", "B"},
+	} {
+		if tc.act.Description() != tc.description {
+			t.Fatalf("description is not correct: %s", tc.act.Description())
+		}
+		if runDescription(t, tc.act) != tc.ran {
+			t.Fatalf("branch chosen by %s is not correct", tc.description)
+		}
+	}
+}
+
+func Test_When_Lazy(t *testing.T) {
+	var flag bool
+	act := Sequential(
+		WrapAction("SetFlag", func(ctx context.Context) (ctrl.Result, error) {
+			flag = true
+			return NoRequeue()
+		}),
+		When(PredicateFunc(func(ctx context.Context) (bool, error) {
+			return flag, nil
+		}), namedAction("A", ctrl.Result{Requeue: true}, nil)),
+	)
+
+	result, err := act.Run(context.Background())
+	if err != nil || !result.Requeue {
+		t.Fatal("predicate should be evaluated at run time")
+	}
+
+	errFailed := errors.New("failed")
+	_, err = When(PredicateFunc(func(ctx context.Context) (bool, error) {
+		return false, errFailed
+	}), Nop).Run(context.Background())
+	if !errors.Is(err, errFailed) {
+		t.Fatal("error of predicate should be returned")
+	}
+}
+
+func Test_IfElse(t *testing.T) {
+	a, b := namedAction("A", ctrl.Result{}, nil), namedAction("B", ctrl.Result{}, nil)
+
+	if IfElse(constPredicate(true), a, b).Description() != "IfElse(A, B)" {
+		t.Fatal("description of if-else is not correct")
+	}
+	if runDescription(t, IfElse(constPredicate(true), a, b)) != "A" {
+		t.Fatal("then branch should run")
+	}
+	if runDescription(t, IfElse(constPredicate(false), a, b)) != "B" {
+		t.Fatal("else branch should run")
+	}
+}
+
+func Test_Switch(t *testing.T) {
+	a, b, c := namedAction("A", ctrl.Result{}, nil), namedAction("B", ctrl.Result{}, nil), namedAction("C", ctrl.Result{}, nil)
+
+	act := Switch(Case(constPredicate(false), a), Case(constPredicate(true), b), Default(c))
+	if act.Description() != "Switch(Case(A), Case(B), Default(C))" {
+		t.Fatal("description of switch is not correct")
+	}
+	if runDescription(t, act) != "B" {
+		t.Fatal("first case chosen should run")
+	}
+	if runDescription(t, Switch(Case(constPredicate(false), a), Default(c))) != "C" {
+		t.Fatal("default case should run")
+	}
+
+	result, err := Switch(Case(constPredicate(false), a)).Run(context.Background())
+	if NeedsRequeue(result, err) {
+		t.Fatal("switch should do nothing if no case is chosen")
+	}
+}
@@ -0,0 +1,167 @@
+package ctrlkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ControllerIndexKey is the key of the field index of the controller names.
+const ControllerIndexKey = ".metadata.controller"
+
+// IndexByController returns an indexer extracting the name of the controller of the object,
+// if the controller is of the given apiVersion and kind.
+func IndexByController(apiVersion, kind string) client.IndexerFunc {
+	return func(obj client.Object) []string {
+		for _, ref := range obj.GetOwnerReferences() {
+			if ref.Controller != nil && *ref.Controller && ref.APIVersion == apiVersion && ref.Kind == kind {
+				return []string{ref.Name}
+			}
+		}
+		return nil
+	}
+}
+
+// IndexByFieldPath returns an indexer extracting the value of the field at the path, e.g.,
+// ".spec.nodeName". Objects without the field or with a non-scalar value aren't indexed.
+func IndexByFieldPath(path string) client.IndexerFunc {
+	segments := strings.Split(strings.TrimPrefix(path, "."), ".")
+
+	return func(obj client.Object) []string {
+		switch path {
+		case ".metadata.name", "metadata.name":
+			return []string{obj.GetName()}
+		case ".metadata.namespace", "metadata.namespace":
+			return []string{obj.GetNamespace()}
+		}
+
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil
+		}
+		value, found, err := unstructured.NestedFieldNoCopy(u, segments...)
+		if !found || err != nil {
+			return nil
+		}
+		switch value.(type) {
+		case string, bool, int64, float64:
+			return []string{fmt.Sprint(value)}
+		default:
+			return nil
+		}
+	}
+}
+
+// FieldIndex is an index of the field of a type of objects.
+type FieldIndex struct {
+	// Object is an object of the type indexed.
+	Object client.Object
+	// Field is the name of the field, e.g., ".metadata.controller".
+	Field string
+	// Extractor extracts the values of the field.
+	Extractor client.IndexerFunc
+}
+
+// RegisterFieldIndexes registers the field indexes to the indexer, e.g., the one got by
+// GetFieldIndexer of ctrl.Manager.
+func RegisterFieldIndexes(ctx context.Context, indexer client.FieldIndexer, indexes ...FieldIndex) error {
+	for _, index := range indexes {
+		if err := indexer.IndexField(ctx, index.Object, index.Field, index.Extractor); err != nil {
+			return fmt.Errorf("unable to index field %s of %T: %w", index.Field, index.Object, err)
+		}
+	}
+	return nil
+}
+
+type indexedClient struct {
+	client.Client
+	indexes map[schema.GroupVersionKind]map[string]client.IndexerFunc
+}
+
+func (c *indexedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.FieldSelector == nil || listOpts.FieldSelector.Empty() {
+		return c.Client.List(ctx, list, opts...)
+	}
+
+	gvk, err := apiutil.GVKForObject(list, c.Scheme())
+	if err != nil {
+		return err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+
+	// Resolve the indexes of the fields selected, as the cache does.
+	requirements := listOpts.FieldSelector.Requirements()
+	extractors := make([]client.IndexerFunc, 0, len(requirements))
+	for _, r := range requirements {
+		extractor, ok := c.indexes[gvk][r.Field]
+		if !ok {
+			return fmt.Errorf("index with name field:%s does not exist", r.Field)
+		}
+		extractors = append(extractors, extractor)
+	}
+
+	// List without the field selector and filter the objects with the indexes.
+	listOpts.FieldSelector = nil
+	if err := c.Client.List(ctx, list, listOpts); err != nil {
+		return err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	filtered := make([]runtime.Object, 0, len(objs))
+	for _, obj := range objs {
+		if matchesFieldRequirements(obj.(client.Object), requirements, extractors) {
+			filtered = append(filtered, obj)
+		}
+	}
+	return meta.SetList(list, filtered)
+}
+
+func matchesFieldRequirements(obj client.Object, requirements fields.Requirements, extractors []client.IndexerFunc) bool {
+	for i, r := range requirements {
+		var found bool
+		for _, v := range extractors[i](obj) {
+			if v == r.Value {
+				found = true
+				break
+			}
+		}
+		if found != (r.Operator != selection.NotEquals) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithFieldIndexes wraps the client, e.g., a fake one in tests, to serve the field selectors
+// of List with the field indexes, like the cache of ctrl.Manager does. Listing with fields
+// not indexed fails.
+func WithFieldIndexes(c client.Client, indexes ...FieldIndex) client.Client {
+	ic := &indexedClient{
+		Client:  c,
+		indexes: make(map[schema.GroupVersionKind]map[string]client.IndexerFunc),
+	}
+	for _, index := range indexes {
+		gvk, err := apiutil.GVKForObject(index.Object, c.Scheme())
+		if err != nil {
+			panic(fmt.Sprintf("unable to get gvk of %T: %s", index.Object, err))
+		}
+		if ic.indexes[gvk] == nil {
+			ic.indexes[gvk] = make(map[string]client.IndexerFunc)
+		}
+		ic.indexes[gvk][index.Field] = index.Extractor
+	}
+	return ic
+}
@@ -0,0 +1,67 @@
+package ctrlkit
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_IndexByController(t *testing.T) {
+	objs := newOwnedConfigMaps(newTestOwner(), "a")
+	if names := IndexByController("v1", "ConfigMap")(objs[0]); len(names) != 1 || names[0] != "owner" {
+		t.Fatalf("name of controller is not indexed: %v", names)
+	}
+	if names := IndexByController("apps/v1", "Deployment")(objs[0]); len(names) != 0 {
+		t.Fatal("controller of other kinds should not be indexed")
+	}
+}
+
+func Test_IndexByFieldPath(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node"},
+	}
+	if v := IndexByFieldPath(".spec.nodeName")(pod); len(v) != 1 || v[0] != "node" {
+		t.Fatalf("field is not indexed: %v", v)
+	}
+	if v := IndexByFieldPath("metadata.name")(pod); len(v) != 1 || v[0] != "pod" {
+		t.Fatalf("name is not indexed: %v", v)
+	}
+	if v := IndexByFieldPath(".spec.containers")(pod); len(v) != 0 {
+		t.Fatal("non-scalar fields should not be indexed")
+	}
+	if v := IndexByFieldPath(".spec.unknown")(pod); len(v) != 0 {
+		t.Fatal("missing fields should not be indexed")
+	}
+}
+
+func Test_WithFieldIndexes(t *testing.T) {
+	owner := newTestOwner()
+	objs := append(newOwnedConfigMaps(owner, "a", "b"), newOwnedConfigMaps(nil, "c")...)
+	c := WithFieldIndexes(fake.NewClientBuilder().WithObjects(objs...).Build(), FieldIndex{
+		Object:    &corev1.ConfigMap{},
+		Field:     ControllerIndexKey,
+		Extractor: IndexByController("v1", "ConfigMap"),
+	})
+
+	var list corev1.ConfigMapList
+	if err := c.List(context.Background(), &list, client.InNamespace("default"),
+		client.MatchingFields{ControllerIndexKey: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 {
+		t.Fatalf("objects should be filtered by field: %d", len(list.Items))
+	}
+
+	if err := c.List(context.Background(), &list); err != nil || len(list.Items) != 3 {
+		t.Fatal("objects should be listed without field selectors")
+	}
+
+	if err := c.List(context.Background(), &list, client.MatchingFields{".spec.unknown": "a"}); err == nil {
+		t.Fatal("listing with fields not indexed should fail")
+	}
+}
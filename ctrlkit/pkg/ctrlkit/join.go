@@ -2,6 +2,7 @@ package ctrlkit
 
 import (
 	"context"
+	"errors"
 	"sync"
 
 	multierr "github.com/hashicorp/go-multierror"
@@ -58,35 +59,90 @@ func joinResultAndErr(result ctrl.Result, err error, lresult ctrl.Result, lerr e
 func runJoinActions(ctx context.Context, actions ...ReconcileAction) (result ctrl.Result, err error) {
 	// Run actions one-by-one and join results.
 	for _, act := range actions {
-		lr, lerr := act.Run(ctx)
+		lr, lerr := runChild(ctx, act)
 		result, err = joinResultAndErr(result, err, lr, lerr)
 	}
 	return
 }
 
 func runJoinActionsInParallel(ctx context.Context, actions ...ReconcileAction) (result ctrl.Result, err error) {
+	return runJoinActionsInParallelWithOptions(ctx, parallelJoinOptions{}, actions...)
+}
+
+type parallelJoinOptions struct {
+	// limit is the maximum number of actions running at the same time. A non-positive
+	// limit means no limit.
+	limit int
+
+	// failFast cancels the context of the running actions and skips the pending ones
+	// once any action returns a non-nil error.
+	failFast bool
+}
+
+func runJoinActionsInParallelWithOptions(ctx context.Context, opts parallelJoinOptions, actions ...ReconcileAction) (result ctrl.Result, err error) {
 	lresults := make([]ctrl.Result, len(actions))
 	lerrs := make([]error, len(actions))
 
+	// Derive a cancellable context and record the first failed action when fail-fast.
+	parentCtx := ctx
+	failed := -1
+	var cancel context.CancelFunc = func() {}
+	var failOnce sync.Once
+	if opts.failFast {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	// Limit the concurrency with a semaphore if required.
+	var sem chan struct{}
+	if opts.limit > 0 && opts.limit < len(actions) {
+		sem = make(chan struct{}, opts.limit)
+	}
+
 	// Run each action in a new goroutine and organize with WaitGroup.
 	wg := sync.WaitGroup{}
+	wg.Add(len(actions))
 
 	for i := range actions {
-		act := actions[i]
+		i, act := i, actions[i]
 		lresult, lerr := &lresults[i], &lerrs[i]
+		if sem != nil {
+			sem <- struct{}{}
+		}
 		go func() {
 			defer wg.Done()
-
-			*lresult, *lerr = act.Run(ctx)
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+
+			// Skip the pending actions if it has already failed, and leave a trace of it.
+			if opts.failFast && ctx.Err() != nil && parentCtx.Err() == nil {
+				skipSpan(ctx, act)
+				return
+			}
+
+			*lresult, *lerr = runChild(ctx, act)
+
+			if opts.failFast && *lerr != nil {
+				failOnce.Do(func() {
+					failed = i
+					cancel()
+				})
+			}
 		}()
 	}
 
 	// Wait should set a memory barrier.
 	wg.Wait()
 
-	// Join results.
+	// Join results. The cancellation errors caused by the fail-fast are dropped since
+	// they are the consequences rather than the causes.
 	for i := 0; i < len(actions); i++ {
-		result, err = joinResultAndErr(result, err, lresults[i], lerrs[i])
+		lerr := lerrs[i]
+		if failed >= 0 && i != failed && parentCtx.Err() == nil && errors.Is(lerr, context.Canceled) {
+			lerr = nil
+		}
+		result, err = joinResultAndErr(result, err, lresults[i], lerr)
 	}
 
 	return
@@ -135,7 +191,10 @@ func (act *joinAction) Description() string {
 	}
 }
 
-func (act *joinAction) Run(ctx context.Context) (ctrl.Result, error) {
+func (act *joinAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
 	return act.runner.Run(ctx, act.actions...)
 }
 
@@ -165,3 +224,31 @@ func JoinOrdered(actions ...ReconcileAction) ReconcileAction {
 func JoinInParallel(actions ...ReconcileAction) ReconcileAction {
 	return join(actions, parallelJoinRunner)
 }
+
+// JoinInParallelWithLimit organizes the actions in a split-join flow and executes them in
+// parallel, with at most limit actions running at the same time. A non-positive limit means
+// no limit, so that a limit derived from data never fails the reconcile.
+func JoinInParallelWithLimit(limit int, actions ...ReconcileAction) ReconcileAction {
+	return join(actions, parallelJoinRunFunc(func(ctx context.Context, actions ...ReconcileAction) (ctrl.Result, error) {
+		return runJoinActionsInParallelWithOptions(ctx, parallelJoinOptions{limit: limit}, actions...)
+	}))
+}
+
+// JoinInParallelFailFast organizes the actions in a split-join flow and executes them in
+// parallel. Once any action returns a non-nil error (including ErrExit), the context of
+// the others is cancelled. The results are still joined after all actions return.
+func JoinInParallelFailFast(actions ...ReconcileAction) ReconcileAction {
+	return join(actions, parallelJoinRunFunc(func(ctx context.Context, actions ...ReconcileAction) (ctrl.Result, error) {
+		return runJoinActionsInParallelWithOptions(ctx, parallelJoinOptions{failFast: true}, actions...)
+	}))
+}
+
+// JoinInParallelWithLimitFailFast combines JoinInParallelWithLimit and JoinInParallelFailFast.
+// It executes the actions in parallel with at most limit actions running at the same time,
+// and once any action returns a non-nil error, the others are cancelled and the pending ones
+// are skipped. A non-positive limit means no limit.
+func JoinInParallelWithLimitFailFast(limit int, actions ...ReconcileAction) ReconcileAction {
+	return join(actions, parallelJoinRunFunc(func(ctx context.Context, actions ...ReconcileAction) (ctrl.Result, error) {
+		return runJoinActionsInParallelWithOptions(ctx, parallelJoinOptions{limit: limit, failFast: true}, actions...)
+	}))
+}
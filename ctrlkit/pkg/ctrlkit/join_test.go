@@ -1,6 +1,14 @@
 package ctrlkit
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
 
 func Test_Join(t *testing.T) {
 	if Join(Nop, Nop).Description() != "Join(Nop, Nop)" {
@@ -17,3 +25,135 @@ func Test_JoinInParallel(t *testing.T) {
 		t.Fatal("description of parallel join is not correct")
 	}
 }
+
+func Test_JoinInParallelWithLimit(t *testing.T) {
+	if JoinInParallelWithLimit(2, Nop, Nop, Nop).Description() != "ParallelJoin(Nop, Nop, Nop)" {
+		t.Fatal("description of parallel join is not correct")
+	}
+
+	var running, maxRunning int32
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	actions := make([]ReconcileAction, 10)
+	for i := range actions {
+		after := time.Duration(i+1) * time.Second
+		actions[i] = WrapAction("Block", func(ctx context.Context) (ctrl.Result, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			started <- struct{}{}
+			<-release
+			return RequeueAfter(after)
+		})
+	}
+
+	go func() {
+		// Release the actions once the limit is reached.
+		for i := 0; i < 3; i++ {
+			<-started
+		}
+		close(release)
+	}()
+
+	result, err := JoinInParallelWithLimit(3, actions...).Run(context.Background())
+	if err != nil || result.RequeueAfter != time.Second {
+		t.Fatalf("result of parallel join is not correct: %v, %v", result, err)
+	}
+	if maxRunning != 3 {
+		t.Fatalf("concurrency doesn't reach or exceeds the limit: %d", maxRunning)
+	}
+}
+
+func Test_JoinInParallelWithLimit_NonPositive(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		var running int32
+		release := make(chan struct{})
+		actions := make([]ReconcileAction, 3)
+		for i := range actions {
+			actions[i] = WrapAction("Block", func(ctx context.Context) (ctrl.Result, error) {
+				// The last one releases all, which is reachable only without the limit.
+				if atomic.AddInt32(&running, 1) == int32(len(actions)) {
+					close(release)
+				}
+				<-release
+				return NoRequeue()
+			})
+		}
+
+		if _, err := JoinInParallelWithLimit(limit, actions...).Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_JoinInParallelFailFast(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	for _, expectedErr := range []error{errFailed, ErrExit} {
+		expectedErr := expectedErr
+		waiting, requeued := make(chan struct{}), make(chan struct{})
+		wait := WrapAction("Wait", func(ctx context.Context) (ctrl.Result, error) {
+			close(waiting)
+			select {
+			case <-ctx.Done():
+				return RequeueIfError(ctx.Err())
+			case <-time.After(10 * time.Second):
+				return RequeueIfError(errors.New("not cancelled"))
+			}
+		})
+		requeue := WrapAction("Requeue", func(ctx context.Context) (ctrl.Result, error) {
+			defer close(requeued)
+			return RequeueAfter(time.Second)
+		})
+		fail := WrapAction("Fail", func(ctx context.Context) (ctrl.Result, error) {
+			<-waiting
+			<-requeued
+			return RequeueIfError(expectedErr)
+		})
+
+		result, err := JoinInParallelFailFast(wait, requeue, fail).Run(context.Background())
+		if err != expectedErr {
+			t.Fatalf("error of parallel join is not correct: %v", err)
+		}
+		if result.RequeueAfter != time.Second {
+			t.Fatalf("result of parallel join is not correct: %v", result)
+		}
+	}
+}
+
+func Test_JoinInParallelWithLimitFailFast(t *testing.T) {
+	errFailed := errors.New("failed")
+	var ran int32
+	fail := WrapAction("Fail", func(ctx context.Context) (ctrl.Result, error) {
+		return RequeueIfError(errFailed)
+	})
+	pending := WrapAction("Pending", func(ctx context.Context) (ctrl.Result, error) {
+		atomic.AddInt32(&ran, 1)
+		return NoRequeue()
+	})
+
+	// With the limit of 1, the pending ones start only after the failure.
+	recorder := NewTraceRecorder()
+	ctx := WithTracer(context.Background(), recorder)
+	_, err := JoinInParallelWithLimitFailFast(1, fail, pending, pending).Run(ctx)
+	if err != errFailed {
+		t.Fatalf("error of parallel join is not correct: %v", err)
+	}
+	if ran != 0 {
+		t.Fatalf("pending actions should be skipped: %d", ran)
+	}
+
+	spans := recorder.Spans()
+	if len(spans) != 1 || len(spans[0].Children) != 3 {
+		t.Fatal("spans of parallel join are not correct")
+	}
+	for i, span := range spans[0].Children {
+		if span.Skipped != (i > 0) {
+			t.Fatalf("span %d is not correct: %+v", i, span)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package ctrlkit
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// MustParseLabelRequirements parses the set-based label requirements, e.g.,
+// "tier in (a,b),!legacy". It panics if the requirements are malformed, so it's only
+// supposed to be used with the ones validated before, e.g., by the generator.
+func MustParseLabelRequirements(selector string) []labels.Requirement {
+	requirements, err := labels.ParseToRequirements(selector)
+	if err != nil {
+		panic(fmt.Sprintf("invalid label requirements %q: %s", selector, err))
+	}
+	return requirements
+}
+
+// LabelSelector returns the selector matching both the labels in set and the requirements.
+// Like client.MatchingLabels, the labels in set aren't validated.
+func LabelSelector(set map[string]string, requirements ...labels.Requirement) labels.Selector {
+	return labels.SelectorFromValidatedSet(set).Add(requirements...)
+}
@@ -0,0 +1,38 @@
+package ctrlkit
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func Test_LabelSelector(t *testing.T) {
+	selector := LabelSelector(map[string]string{"app": "example"},
+		MustParseLabelRequirements("tier in (a,b),!canary")...)
+
+	for set, matches := range map[string]bool{
+		"app=example,tier=a":             true,
+		"app=example,tier=c":             false,
+		"app=example,tier=b,canary=true": false,
+		"app=other,tier=a":               false,
+	} {
+		if selector.Matches(labels.Set(parseTestLabels(t, set))) != matches {
+			t.Fatalf("selector %s should match %s: %v", selector, set, matches)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("malformed requirements should panic")
+		}
+	}()
+	MustParseLabelRequirements("tier in (a")
+}
+
+func parseTestLabels(t *testing.T, set string) map[string]string {
+	s, err := labels.ConvertSelectorToLabelsMap(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
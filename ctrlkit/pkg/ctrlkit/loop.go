@@ -1,6 +1,10 @@
 package ctrlkit
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -30,3 +34,121 @@ func RequeueIfError(err error) (ctrl.Result, error) {
 func NoRequeue() (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
+
+// ErrConditionNotMet is returned by Until when the condition still doesn't hold after the
+// maximum iterations.
+var ErrConditionNotMet = errors.New("condition not met")
+
+// conditionNotMetError is ErrConditionNotMet caused by another error, e.g., the error of the
+// context. It matches both ErrConditionNotMet and the cause with errors.Is.
+type conditionNotMetError struct {
+	cause error
+}
+
+func (e *conditionNotMetError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrConditionNotMet, e.cause)
+}
+
+func (e *conditionNotMetError) Is(target error) bool {
+	return target == ErrConditionNotMet
+}
+
+func (e *conditionNotMetError) Unwrap() error {
+	return e.cause
+}
+
+type untilAction struct {
+	cond          Predicate
+	inner         ReconcileAction
+	interval      time.Duration
+	maxIterations int
+}
+
+func (act *untilAction) Description() string {
+	return describeWithPredicate("Until", act.cond, act.inner.Description(), act.interval.String(), strconv.Itoa(act.maxIterations))
+}
+
+func (act *untilAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
+	for n := 0; ; n++ {
+		ok, err := evaluate(ctx, act.cond)
+		if err != nil {
+			return RequeueIfError(err)
+		}
+		if ok {
+			return NoRequeue()
+		}
+		if act.maxIterations > 0 && n >= act.maxIterations {
+			return RequeueIfError(fmt.Errorf("%w after %d iterations", ErrConditionNotMet, n))
+		}
+
+		// Break the loop and return the control if the action requires a requeue.
+		if result, err := runChild(ctx, act.inner); NeedsRequeue(result, err) {
+			return result, err
+		}
+
+		// Wait before the next iteration, or give up when the context is done.
+		timer := time.NewTimer(act.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return RequeueIfError(&conditionNotMetError{cause: ctx.Err()})
+		case <-timer.C:
+		}
+	}
+}
+
+// Until returns an action that runs the given action repeatedly within the reconcile, until
+// the condition evaluates to true. The condition is evaluated before each iteration, and
+// iterations are separated by the interval. It breaks when the action requires a requeue,
+// and fails with ErrConditionNotMet when the context is done or the action has run for
+// maxIterations times. The error of the context is wrapped as well when it's done. A
+// non-positive maxIterations means no limit.
+func Until(cond Predicate, act ReconcileAction, interval time.Duration, maxIterations int) ReconcileAction {
+	return &untilAction{cond: cond, inner: act, interval: interval, maxIterations: maxIterations}
+}
+
+type requeueUntilAction struct {
+	cond     Predicate
+	inner    ReconcileAction
+	interval time.Duration
+}
+
+func (act *requeueUntilAction) Description() string {
+	return describeWithPredicate("RequeueUntil", act.cond, act.inner.Description(), act.interval.String())
+}
+
+func (act *requeueUntilAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
+	ok, err := evaluate(ctx, act.cond)
+	if err != nil {
+		return RequeueIfError(err)
+	}
+	if ok {
+		return NoRequeue()
+	}
+
+	if result, err := runChild(ctx, act.inner); NeedsRequeue(result, err) {
+		return result, err
+	}
+
+	// Check again so that a satisfied condition doesn't cost another reconcile.
+	if ok, err = evaluate(ctx, act.cond); err != nil {
+		return RequeueIfError(err)
+	}
+	if ok {
+		return NoRequeue()
+	}
+	return RequeueAfter(act.interval)
+}
+
+// RequeueUntil is the non-blocking variant of Until. It runs the given action once unless
+// the condition evaluates to true, and requeues after the interval if the condition still
+// doesn't hold, so that the loop is driven by the reconciles.
+func RequeueUntil(cond Predicate, act ReconcileAction, interval time.Duration) ReconcileAction {
+	return &requeueUntilAction{cond: cond, inner: act, interval: interval}
+}
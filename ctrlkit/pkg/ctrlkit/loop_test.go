@@ -0,0 +1,110 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func countingUntil(count *int, target int) (Predicate, ReconcileAction) {
+	return PredicateFunc(func(ctx context.Context) (bool, error) {
+			return *count >= target, nil
+		}), WrapAction("Step", func(ctx context.Context) (ctrl.Result, error) {
+			*count++
+			return NoRequeue()
+		})
+}
+
+func Test_Until(t *testing.T) {
+	var count int
+	cond, step := countingUntil(&count, 3)
+
+	act := Until(cond, step, time.Millisecond, 5)
+	if act.Description() != "Until(Step, 1ms, 5)" {
+		t.Fatal("description of until is not correct")
+	}
+	if Until(Named("Done", cond.(PredicateFunc)), step, time.Millisecond, 5).Description() != "Until(Done, Step, 1ms, 5)" {
+		t.Fatal("description of until should contain the name of the condition")
+	}
+
+	result, err := act.Run(context.Background())
+	if NeedsRequeue(result, err) || count != 3 {
+		t.Fatalf("until should stop when condition holds: count = %d, err = %v", count, err)
+	}
+
+	count = 0
+	_, err = Until(cond, step, time.Millisecond, 2).Run(context.Background())
+	if !errors.Is(err, ErrConditionNotMet) || count != 2 {
+		t.Fatalf("until should stop after max iterations: count = %d, err = %v", count, err)
+	}
+}
+
+func Test_Until_BreakOnRequeue(t *testing.T) {
+	var count int
+	act := Until(PredicateFunc(func(ctx context.Context) (bool, error) {
+		return false, nil
+	}), WrapAction("Requeue", func(ctx context.Context) (ctrl.Result, error) {
+		count++
+		return RequeueAfter(time.Second)
+	}), time.Millisecond, 0)
+
+	result, err := act.Run(context.Background())
+	if err != nil || result.RequeueAfter != time.Second || count != 1 {
+		t.Fatal("until should break when the action requires a requeue")
+	}
+}
+
+func Test_Until_ContextDone(t *testing.T) {
+	var count int
+	cond, step := countingUntil(&count, 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := Until(cond, step, time.Hour, 0).Run(ctx)
+	if !errors.Is(err, ErrConditionNotMet) || count != 1 {
+		t.Fatalf("until should stop when context is done: count = %d, err = %v", count, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error of context should be wrapped: %v", err)
+	}
+
+	// Canceled.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = Until(cond, step, time.Hour, 0).Run(ctx)
+	if !errors.Is(err, ErrConditionNotMet) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("until should stop when context is canceled: %v", err)
+	}
+	if err.Error() != "condition not met: context canceled" {
+		t.Fatalf("error message is not correct: %s", err)
+	}
+}
+
+func Test_RequeueUntil(t *testing.T) {
+	var count int
+	cond, step := countingUntil(&count, 2)
+
+	act := RequeueUntil(cond, step, time.Second)
+	if act.Description() != "RequeueUntil(Step, 1s)" {
+		t.Fatal("description of requeue until is not correct")
+	}
+
+	result, err := act.Run(context.Background())
+	if err != nil || result.RequeueAfter != time.Second || count != 1 {
+		t.Fatal("requeue until should requeue when condition doesn't hold")
+	}
+
+	result, err = act.Run(context.Background())
+	if NeedsRequeue(result, err) || count != 2 {
+		t.Fatal("requeue until should not requeue when condition holds after the run")
+	}
+
+	result, err = act.Run(context.Background())
+	if NeedsRequeue(result, err) || count != 2 {
+		t.Fatal("requeue until should not run when condition holds")
+	}
+}
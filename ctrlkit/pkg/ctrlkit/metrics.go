@@ -0,0 +1,141 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Outcomes of the actions recorded in metrics.
+const (
+	OutcomeSuccess      = "success"
+	OutcomeRequeue      = "requeue"
+	OutcomeRequeueAfter = "requeue_after"
+	OutcomeError        = "error"
+	OutcomeExit         = "exit"
+)
+
+// OutcomeOf classifies the result and error of an action into one of the outcomes.
+func OutcomeOf(result ctrl.Result, err error) string {
+	switch {
+	case err == ErrExit:
+		return OutcomeExit
+	case err != nil:
+		return OutcomeError
+	case result.Requeue:
+		return OutcomeRequeue
+	case result.RequeueAfter > 0:
+		return OutcomeRequeueAfter
+	default:
+		return OutcomeSuccess
+	}
+}
+
+type actionMetrics struct {
+	duration *prometheus.HistogramVec
+	outcomes *prometheus.CounterVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newActionMetrics() *actionMetrics {
+	return &actionMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ctrlkit_action_duration_seconds",
+			Help:    "Duration of the action runs in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"manager", "action"}),
+		outcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ctrlkit_action_outcomes_total",
+			Help: "Total number of the action runs by outcome.",
+		}, []string{"manager", "action", "outcome"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ctrlkit_action_in_flight",
+			Help: "Number of the action runs in flight.",
+		}, []string{"manager", "action"}),
+	}
+}
+
+// registerOrExisting registers the collector, or returns the existing one if there's
+// already an identical one registered.
+func registerOrExisting(registerer prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	if err := registerer.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (m *actionMetrics) register(registerer prometheus.Registerer) error {
+	c, err := registerOrExisting(registerer, m.duration)
+	if err != nil {
+		return err
+	}
+	m.duration = c.(*prometheus.HistogramVec)
+
+	if c, err = registerOrExisting(registerer, m.outcomes); err != nil {
+		return err
+	}
+	m.outcomes = c.(*prometheus.CounterVec)
+
+	if c, err = registerOrExisting(registerer, m.inFlight); err != nil {
+		return err
+	}
+	m.inFlight = c.(*prometheus.GaugeVec)
+
+	return nil
+}
+
+// MetricsHook is an ActionHook recording the duration, outcome and in-flight number of
+// the action runs as Prometheus metrics. The duration is timed from the start of the run
+// carried on the context, which the generated managers set up. See StartActionRun.
+type MetricsHook struct {
+	manager string
+	metrics *actionMetrics
+}
+
+func (h *MetricsHook) PreRun(ctx context.Context, logger logr.Logger, action string, states map[string]runtime.Object) {
+	h.metrics.inFlight.WithLabelValues(h.manager, action).Inc()
+}
+
+func (h *MetricsHook) PostRun(ctx context.Context, logger logr.Logger, action string, result ctrl.Result, err error) {
+	h.metrics.inFlight.WithLabelValues(h.manager, action).Dec()
+	h.metrics.outcomes.WithLabelValues(h.manager, action, OutcomeOf(result, err)).Inc()
+
+	// Runs not started with StartActionRun are not timed.
+	if start, ok := ActionRunStartTime(ctx); ok {
+		h.metrics.duration.WithLabelValues(h.manager, action).Observe(time.Since(start).Seconds())
+	}
+}
+
+// NewMetricsHook returns a MetricsHook of the manager with the metrics registered into the
+// registerer. Hooks of different managers share the same metrics with different labels.
+func NewMetricsHook(registerer prometheus.Registerer, manager string) (*MetricsHook, error) {
+	m := newActionMetrics()
+	if err := m.register(registerer); err != nil {
+		return nil, err
+	}
+
+	return &MetricsHook{
+		manager: manager,
+		metrics: m,
+	}, nil
+}
+
+// DefaultMetricsHook returns a MetricsHook of the manager with the metrics registered into
+// the registry of controller-runtime. It panics if the registration fails.
+func DefaultMetricsHook(manager string) *MetricsHook {
+	hook, err := NewMetricsHook(metrics.Registry, manager)
+	if err != nil {
+		panic(err)
+	}
+	return hook
+}
@@ -0,0 +1,139 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func Test_OutcomeOf(t *testing.T) {
+	for _, tc := range []struct {
+		result   ctrl.Result
+		err      error
+		expected string
+	}{
+		{ctrl.Result{}, nil, OutcomeSuccess},
+		{ctrl.Result{Requeue: true}, nil, OutcomeRequeue},
+		{ctrl.Result{RequeueAfter: time.Second}, nil, OutcomeRequeueAfter},
+		{ctrl.Result{Requeue: true}, errors.New("failed"), OutcomeError},
+		{ctrl.Result{}, ErrExit, OutcomeExit},
+	} {
+		if outcome := OutcomeOf(tc.result, tc.err); outcome != tc.expected {
+			t.Fatalf("outcome is not correct: expected %s, got %s", tc.expected, outcome)
+		}
+	}
+}
+
+func Test_MetricsHook(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	hook, err := NewMetricsHook(registry, "Test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hooks of other managers share the metrics.
+	otherHook, err := NewMetricsHook(registry, "Other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := StartActionRun(context.Background())
+	hook.PreRun(ctx, logr.Discard(), "A", nil)
+	if v := testutil.ToFloat64(hook.metrics.inFlight.WithLabelValues("Test", "A")); v != 1 {
+		t.Fatalf("in-flight gauge is not correct: %v", v)
+	}
+	hook.PostRun(ctx, logr.Discard(), "A", ctrl.Result{Requeue: true}, nil)
+	hook.PreRun(ctx, logr.Discard(), "A", nil)
+	hook.PostRun(ctx, logr.Discard(), "A", ctrl.Result{}, ErrExit)
+	otherHook.PreRun(ctx, logr.Discard(), "A", nil)
+	otherHook.PostRun(ctx, logr.Discard(), "A", ctrl.Result{}, nil)
+
+	if v := testutil.ToFloat64(hook.metrics.inFlight.WithLabelValues("Test", "A")); v != 0 {
+		t.Fatalf("in-flight gauge is not correct: %v", v)
+	}
+	for outcome, expected := range map[string]float64{
+		OutcomeRequeue: 1,
+		OutcomeExit:    1,
+		OutcomeSuccess: 0,
+	} {
+		if v := testutil.ToFloat64(hook.metrics.outcomes.WithLabelValues("Test", "A", outcome)); v != expected {
+			t.Fatalf("counter of outcome %s is not correct: %v", outcome, v)
+		}
+	}
+	if v := testutil.ToFloat64(otherHook.metrics.outcomes.WithLabelValues("Other", "A", OutcomeSuccess)); v != 1 {
+		t.Fatalf("counter of other manager is not correct: %v", v)
+	}
+
+	if n := testutil.CollectAndCount(hook.metrics.duration); n != 2 {
+		t.Fatalf("histograms are not correct: %d", n)
+	}
+
+	// Runs without a start are counted but not timed.
+	hook.PreRun(context.Background(), logr.Discard(), "B", nil)
+	hook.PostRun(context.Background(), logr.Discard(), "B", ctrl.Result{}, nil)
+	if v := testutil.ToFloat64(hook.metrics.outcomes.WithLabelValues("Test", "B", OutcomeSuccess)); v != 1 {
+		t.Fatalf("counter of untimed run is not correct: %v", v)
+	}
+	if n := testutil.CollectAndCount(hook.metrics.duration); n != 2 {
+		t.Fatalf("untimed run should not be observed: %d", n)
+	}
+}
+
+// durationSampleCount returns the number of the durations observed of the action.
+func durationSampleCount(t *testing.T, registry *prometheus.Registry, manager, action string) uint64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "ctrlkit_action_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["manager"] == manager && labels["action"] == action {
+				return m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
+
+func Test_MetricsHook_ConcurrentRuns(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	hook, err := NewMetricsHook(registry, "Test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Runs of the same action under the same context, e.g., in a parallel join.
+	ctx := context.Background()
+	act := NewAction("A", func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = StartActionRun(ctx)
+		defer func() { hook.PostRun(ctx, logr.Discard(), "A", result, err) }()
+		hook.PreRun(ctx, logr.Discard(), "A", nil)
+		return NoRequeue()
+	})
+	if _, err := JoinInParallel(act, act, act).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := testutil.ToFloat64(hook.metrics.outcomes.WithLabelValues("Test", "A", OutcomeSuccess)); v != 3 {
+		t.Fatalf("counter is not correct: %v", v)
+	}
+	if v := testutil.ToFloat64(hook.metrics.inFlight.WithLabelValues("Test", "A")); v != 0 {
+		t.Fatalf("in-flight gauge is not correct: %v", v)
+	}
+	if n := durationSampleCount(t, registry, "Test", "A"); n != 3 {
+		t.Fatalf("durations are not observed for every run: %d", n)
+	}
+}
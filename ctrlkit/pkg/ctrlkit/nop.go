@@ -12,7 +12,10 @@ func (act *nopAction) Description() string {
 	return "Nop"
 }
 
-func (act *nopAction) Run(ctx context.Context) (ctrl.Result, error) {
+func (act *nopAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	_, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
 	return NoRequeue()
 }
 
@@ -0,0 +1,72 @@
+package ctrlkit
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// OpenTelemetryInstrumentationName is the name of the OpenTelemetry tracer used by ctrlkit.
+const OpenTelemetryInstrumentationName = "github.com/arkbriar/ctrlkit"
+
+// Attributes of the OpenTelemetry spans.
+const (
+	AttributeActionKind           = attribute.Key("ctrlkit.action.kind")
+	AttributeActionSkipped        = attribute.Key("ctrlkit.action.skipped")
+	AttributeResultRequeue        = attribute.Key("ctrlkit.result.requeue")
+	AttributeResultRequeueAfterMs = attribute.Key("ctrlkit.result.requeue_after_ms")
+	AttributeResultExit           = attribute.Key("ctrlkit.result.exit")
+	AttributeResultError          = attribute.Key("ctrlkit.result.error")
+)
+
+type openTelemetrySpan struct {
+	span trace.Span
+}
+
+func (s *openTelemetrySpan) End(result ctrl.Result, err error) {
+	s.span.SetAttributes(
+		AttributeResultRequeue.Bool(result.Requeue),
+		AttributeResultRequeueAfterMs.Int64(result.RequeueAfter.Milliseconds()),
+	)
+	if err == ErrExit {
+		s.span.SetAttributes(AttributeResultExit.Bool(true))
+	} else if err != nil {
+		s.span.SetAttributes(AttributeResultError.String(err.Error()))
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+type openTelemetryTracer struct {
+	tracer trace.Tracer
+}
+
+func (t *openTelemetryTracer) Start(ctx context.Context, kind string, act ReconcileAction) (context.Context, Span) {
+	ctx, span := t.tracer.Start(ctx, act.Description(),
+		trace.WithAttributes(AttributeActionKind.String(kind)),
+	)
+	return ctx, &openTelemetrySpan{span: span}
+}
+
+func (t *openTelemetryTracer) Skip(ctx context.Context, kind string, act ReconcileAction) {
+	_, span := t.tracer.Start(ctx, act.Description(),
+		trace.WithAttributes(AttributeActionKind.String(kind), AttributeActionSkipped.Bool(true)),
+	)
+	span.End()
+}
+
+// NewOpenTelemetryTracer returns a Tracer emitting an OpenTelemetry span for each run of
+// the actions, with the tracer provider given. The global tracer provider is used if it's nil.
+// The spans are named after the descriptions of the actions and nested following the
+// structure of the actions, so they could be found in the same traces as the API calls.
+func NewOpenTelemetryTracer(provider trace.TracerProvider) Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &openTelemetryTracer{tracer: provider.Tracer(OpenTelemetryInstrumentationName)}
+}
@@ -0,0 +1,78 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func Test_OpenTelemetryTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx := WithTracer(context.Background(), NewOpenTelemetryTracer(provider))
+
+	errFailed := errors.New("failed")
+	act := Sequential(
+		JoinOrdered(
+			namedAction("A", ctrl.Result{RequeueAfter: time.Second}, nil),
+			namedAction("B", ctrl.Result{}, errFailed),
+		),
+		namedAction("C", ctrl.Result{}, nil),
+	)
+	if _, err := act.Run(ctx); err != errFailed {
+		t.Fatal("error of sequential is not correct")
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	if len(spans) != 5 {
+		t.Fatalf("number of spans is not correct: %d", len(spans))
+	}
+
+	root, join := spans[act.Description()], spans["Join(A, B)"]
+	a, b, c := spans["A"], spans["B"], spans["C"]
+
+	if root.Parent.IsValid() {
+		t.Fatal("root span should not have a parent")
+	}
+	if join.Parent.SpanID() != root.SpanContext.SpanID() || c.Parent.SpanID() != root.SpanContext.SpanID() {
+		t.Fatal("children of sequential are not correct")
+	}
+	if a.Parent.SpanID() != join.SpanContext.SpanID() || b.Parent.SpanID() != join.SpanContext.SpanID() {
+		t.Fatal("children of join are not correct")
+	}
+
+	if v, _ := spanAttribute(root, AttributeActionKind); v.AsString() != KindSequential {
+		t.Fatal("kind of root span is not correct")
+	}
+	if v, _ := spanAttribute(a, AttributeResultRequeueAfterMs); v.AsInt64() != 1000 {
+		t.Fatal("requeue after of span A is not correct")
+	}
+	if b.Status.Code != codes.Error {
+		t.Fatal("status of span B is not correct")
+	}
+	if v, _ := spanAttribute(b, AttributeResultError); v.AsString() != "failed" {
+		t.Fatal("error of span B is not correct")
+	}
+	if v, _ := spanAttribute(c, AttributeActionSkipped); !v.AsBool() {
+		t.Fatal("span C should be skipped")
+	}
+}
@@ -16,9 +16,12 @@ func (act *parallelAction) Description() string {
 }
 
 func (act *parallelAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
 	done := make(chan bool)
 	go func() {
-		result, err = act.inner.Run(ctx)
+		result, err = runChild(ctx, act.inner)
 		done <- true
 	}()
 	<-done
@@ -0,0 +1,76 @@
+package ctrlkit
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PruneOptions are the options of PruneOwnedObjects.
+type PruneOptions struct {
+	// PropagationPolicy is the propagation policy of the deletions. It defaults to
+	// background.
+	PropagationPolicy metav1.DeletionPropagation
+	// DryRun only reports the objects to prune without deleting them.
+	DryRun bool
+}
+
+// PruneOption configures the PruneOptions.
+type PruneOption func(*PruneOptions)
+
+// PrunePropagationPolicy sets the propagation policy of the deletions.
+func PrunePropagationPolicy(policy metav1.DeletionPropagation) PruneOption {
+	return func(opts *PruneOptions) {
+		opts.PropagationPolicy = policy
+	}
+}
+
+// PruneDryRun sets the dry-run mode, in which the objects to prune are only reported.
+func PruneDryRun(dryRun bool) PruneOption {
+	return func(opts *PruneOptions) {
+		opts.DryRun = dryRun
+	}
+}
+
+// PruneResult is the result of PruneOwnedObjects.
+type PruneResult struct {
+	// Pruned are the names of the objects pruned, or to prune in dry-run mode.
+	Pruned []string
+	// DryRun tells if the objects are not deleted actually.
+	DryRun bool
+}
+
+// PruneOwnedObjects deletes the objects controlled by the owner whose names aren't in the
+// desired names. Objects not controlled by the owner or already being deleted are left
+// untouched, and the objects not found are ignored.
+func PruneOwnedObjects(ctx context.Context, c client.Client, owner client.Object, objs []client.Object, desired []string, opts ...PruneOption) (PruneResult, error) {
+	options := PruneOptions{PropagationPolicy: metav1.DeletePropagationBackground}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	desiredSet := make(map[string]struct{}, len(desired))
+	for _, name := range desired {
+		desiredSet[name] = struct{}{}
+	}
+
+	result := PruneResult{DryRun: options.DryRun}
+	for _, obj := range objs {
+		if _, ok := desiredSet[obj.GetName()]; ok {
+			continue
+		}
+		if !ValidateOwnership(obj, owner) || IsBeingDeleted(obj) {
+			continue
+		}
+
+		if !options.DryRun {
+			if err := c.Delete(ctx, obj, client.PropagationPolicy(options.PropagationPolicy)); client.IgnoreNotFound(err) != nil {
+				return result, fmt.Errorf("unable to delete object %s: %w", client.ObjectKeyFromObject(obj), err)
+			}
+		}
+		result.Pruned = append(result.Pruned, obj.GetName())
+	}
+	return result, nil
+}
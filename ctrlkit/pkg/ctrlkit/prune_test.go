@@ -0,0 +1,65 @@
+package ctrlkit
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newOwnedConfigMaps(owner client.Object, names ...string) []client.Object {
+	objs := make([]client.Object, 0, len(names))
+	for _, name := range names {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+		}
+		if owner != nil {
+			cm.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(owner, corev1.SchemeGroupVersion.WithKind("ConfigMap"))}
+		}
+		objs = append(objs, cm)
+	}
+	return objs
+}
+
+func countConfigMaps(t *testing.T, c client.Client) int {
+	var list corev1.ConfigMapList
+	if err := c.List(context.Background(), &list, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	return len(list.Items)
+}
+
+func Test_PruneOwnedObjects(t *testing.T) {
+	owner := newTestOwner()
+	objs := append(newOwnedConfigMaps(owner, "a", "b", "c"), newOwnedConfigMaps(nil, "d")...)
+	c := fake.NewClientBuilder().WithObjects(objs...).Build()
+
+	// Dry run, only report.
+	result, err := PruneOwnedObjects(context.Background(), c, owner, objs, []string{"a"}, PruneDryRun(true))
+	if err != nil || !result.DryRun || len(result.Pruned) != 2 {
+		t.Fatalf("objects to prune should be reported: %v, %v", result, err)
+	}
+	if countConfigMaps(t, c) != 4 {
+		t.Fatal("objects should not be deleted in dry-run mode")
+	}
+
+	result, err = PruneOwnedObjects(context.Background(), c, owner, objs, []string{"a"},
+		PrunePropagationPolicy(metav1.DeletePropagationForeground))
+	if err != nil || result.DryRun || len(result.Pruned) != 2 || result.Pruned[0] != "b" || result.Pruned[1] != "c" {
+		t.Fatalf("objects should be pruned: %v, %v", result, err)
+	}
+	if countConfigMaps(t, c) != 2 {
+		t.Fatal("objects are not deleted")
+	}
+
+	// Pruned objects are gone, and not found is ignored.
+	if _, err := PruneOwnedObjects(context.Background(), c, owner, objs, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+}
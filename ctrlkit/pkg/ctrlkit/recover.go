@@ -0,0 +1,90 @@
+package ctrlkit
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// PanicError is the error converted from a panic raised by an action.
+type PanicError struct {
+	// Action is the description of the action that panics.
+	Action string
+
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine where the panic occurs.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in action %s: %v", e.Action, e.Value)
+}
+
+type recoverPanicsKey struct{}
+
+// WithRecoverPanics returns a copy of the context that controls whether the Join, Parallel
+// and Sequential runners recover the panics of their children into *PanicError. They
+// recover by default.
+func WithRecoverPanics(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, recoverPanicsKey{}, enabled)
+}
+
+// RecoverPanicsFromContext tells if the runners recover the panics with the context.
+func RecoverPanicsFromContext(ctx context.Context) bool {
+	enabled, ok := ctx.Value(recoverPanicsKey{}).(bool)
+	return !ok || enabled
+}
+
+// runAndRecover runs the action and converts any panic into a *PanicError.
+func runAndRecover(ctx context.Context, act ReconcileAction) (result ctrl.Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = ctrl.Result{}, &PanicError{
+				Action: act.Description(),
+				Value:  r,
+				Stack:  debug.Stack(),
+			}
+		}
+	}()
+
+	return act.Run(ctx)
+}
+
+// runChild runs the child action of a runner, and recovers from panics unless it's
+// disabled with WithRecoverPanics.
+func runChild(ctx context.Context, act ReconcileAction) (ctrl.Result, error) {
+	if RecoverPanicsFromContext(ctx) {
+		return runAndRecover(ctx, act)
+	}
+	return act.Run(ctx)
+}
+
+type recoverAction struct {
+	inner ReconcileAction
+}
+
+func (act *recoverAction) Description() string {
+	return fmt.Sprintf("Recover(%s)", act.inner.Description())
+}
+
+func (act *recoverAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
+	return runAndRecover(ctx, act.inner)
+}
+
+// Recover wraps the action and converts the panics it raises into *PanicError, whether the
+// recovery of the runners is disabled or not.
+func Recover(act ReconcileAction) ReconcileAction {
+	switch act := act.(type) {
+	case *recoverAction:
+		return act
+	default:
+		return &recoverAction{inner: act}
+	}
+}
@@ -0,0 +1,68 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var panicAction = WrapAction("Panic", func(ctx context.Context) (ctrl.Result, error) {
+	panic("boom")
+})
+
+func assertPanicError(t *testing.T, err error, action string) {
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("error should be a panic error: %v", err)
+	}
+	if panicErr.Action != action || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("panic error is not correct: %v", panicErr)
+	}
+}
+
+func Test_Recover(t *testing.T) {
+	if Recover(panicAction).Description() != "Recover(Panic)" {
+		t.Fatal("description of recover is not correct")
+	}
+
+	if act := Recover(panicAction); Recover(act) != act {
+		t.Fatal("nested recover should be optimized")
+	}
+
+	_, err := Recover(panicAction).Run(context.Background())
+	assertPanicError(t, err, "Panic")
+}
+
+func Test_RecoverPanics(t *testing.T) {
+	for _, act := range []ReconcileAction{
+		JoinOrdered(Nop, panicAction),
+		JoinInParallel(Nop, panicAction),
+		Parallel(panicAction),
+		Sequential(Nop, panicAction),
+	} {
+		_, err := act.Run(context.Background())
+		assertPanicError(t, err, "Panic")
+	}
+}
+
+func Test_RecoverPanics_Disabled(t *testing.T) {
+	ctx := WithRecoverPanics(context.Background(), false)
+	if RecoverPanicsFromContext(ctx) || !RecoverPanicsFromContext(context.Background()) {
+		t.Fatal("recovery should be enabled by default and disabled with the context")
+	}
+
+	// Recover still recovers explicitly.
+	act := Sequential(Nop, panicAction)
+	_, err := Recover(act).Run(ctx)
+	assertPanicError(t, err, act.Description())
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatalf("panic should be propagated: %v", r)
+		}
+	}()
+	_, _ = act.Run(ctx)
+	t.Fatal("panic should be propagated")
+}
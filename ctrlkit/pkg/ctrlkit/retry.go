@@ -0,0 +1,174 @@
+package ctrlkit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Backoff decides the duration to wait before each retry.
+type Backoff interface {
+	// Duration returns the duration to wait before the n-th retry, starting from 1.
+	Duration(n int) time.Duration
+	String() string
+}
+
+type constantBackoff struct {
+	interval time.Duration
+}
+
+func (b *constantBackoff) Duration(n int) time.Duration {
+	return b.interval
+}
+
+func (b *constantBackoff) String() string {
+	return fmt.Sprintf("Constant(%s)", b.interval)
+}
+
+// ConstantBackoff waits for the same interval before each retry.
+func ConstantBackoff(interval time.Duration) Backoff {
+	return &constantBackoff{interval: interval}
+}
+
+type exponentialBackoff struct {
+	initial time.Duration
+	factor  float64
+	max     time.Duration
+}
+
+func (b *exponentialBackoff) Duration(n int) time.Duration {
+	d := float64(b.initial) * math.Pow(b.factor, float64(n-1))
+	if b.max > 0 && d > float64(b.max) {
+		return b.max
+	}
+	return time.Duration(d)
+}
+
+func (b *exponentialBackoff) String() string {
+	return fmt.Sprintf("Exponential(%s, %g, %s)", b.initial, b.factor, b.max)
+}
+
+// ExponentialBackoff waits for initial before the first retry and multiplies the interval
+// by factor before each of the following ones, up to max. A zero max means no limit.
+func ExponentialBackoff(initial time.Duration, factor float64, max time.Duration) Backoff {
+	return &exponentialBackoff{initial: initial, factor: factor, max: max}
+}
+
+type jitteredBackoff struct {
+	inner  Backoff
+	jitter float64
+}
+
+func (b *jitteredBackoff) Duration(n int) time.Duration {
+	d := b.inner.Duration(n)
+	return d + time.Duration(rand.Float64()*b.jitter*float64(d))
+}
+
+func (b *jitteredBackoff) String() string {
+	return fmt.Sprintf("Jittered(%s, %g)", b.inner, b.jitter)
+}
+
+// JitteredBackoff adds a random duration in [0, jitter * d) to each interval d of the
+// inner backoff, so that the conflicting retries are spread.
+func JitteredBackoff(inner Backoff, jitter float64) Backoff {
+	return &jitteredBackoff{inner: inner, jitter: jitter}
+}
+
+// IsTransientError reports if the error is a transient error of the API server, e.g.,
+// a conflict or a server timeout, which is likely to disappear on retry.
+func IsTransientError(err error) bool {
+	return apierrors.IsConflict(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err)
+}
+
+// RetryPolicy describes when and how an action is retried.
+type RetryPolicy struct {
+	// Backoff decides the duration to wait before each retry. No wait if it's nil.
+	Backoff Backoff
+
+	// MaxAttempts is the maximum number of runs, including the first one. Values less
+	// than 1 are regarded as 1.
+	MaxAttempts int
+
+	// Retriable reports if the action should be retried on the error. IsTransientError
+	// is used if it's nil.
+	Retriable func(err error) bool
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	if p.Backoff == nil {
+		return 0
+	}
+	return p.Backoff.Duration(n)
+}
+
+func (p *RetryPolicy) retriable(err error) bool {
+	if err == nil || err == ErrExit {
+		return false
+	}
+	if p.Retriable == nil {
+		return IsTransientError(err)
+	}
+	return p.Retriable(err)
+}
+
+func (p *RetryPolicy) String() string {
+	backoff := "None"
+	if p.Backoff != nil {
+		backoff = p.Backoff.String()
+	}
+	return fmt.Sprintf("%s, %d", backoff, p.maxAttempts())
+}
+
+type retryAction struct {
+	policy RetryPolicy
+	inner  ReconcileAction
+}
+
+func (act *retryAction) Description() string {
+	return fmt.Sprintf("Retry(%s, %s)", act.inner.Description(), act.policy.String())
+}
+
+func (act *retryAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
+	maxAttempts := act.policy.maxAttempts()
+
+	for n := 1; ; n++ {
+		result, err = act.inner.Run(ctx)
+		if n >= maxAttempts || !act.policy.retriable(err) {
+			return
+		}
+
+		// Wait before the next attempt, or give up when the context is done.
+		timer := time.NewTimer(act.policy.backoff(n))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Retry wraps the reconcile action and reruns it in-process on retriable errors
+// following the given policy. The result and error of the last run are returned.
+func Retry(policy RetryPolicy, act ReconcileAction) ReconcileAction {
+	return &retryAction{policy: policy, inner: act}
+}
@@ -0,0 +1,88 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var errConflict = apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "test", errors.New("conflict"))
+
+func countedAction(count *int, errs ...error) ReconcileAction {
+	return WrapAction("Counted", func(ctx context.Context) (ctrl.Result, error) {
+		n := *count
+		*count++
+		if n < len(errs) {
+			return RequeueIfError(errs[n])
+		}
+		return NoRequeue()
+	})
+}
+
+func Test_Retry(t *testing.T) {
+	var count int
+	policy := RetryPolicy{Backoff: ConstantBackoff(time.Millisecond), MaxAttempts: 3}
+
+	_, err := Retry(policy, countedAction(&count, errConflict, errConflict)).Run(context.Background())
+	if err != nil || count != 3 {
+		t.Fatalf("should succeed in the third run: err = %v, count = %d", err, count)
+	}
+
+	count = 0
+	_, err = Retry(policy, countedAction(&count, errConflict, errConflict, errConflict)).Run(context.Background())
+	if !apierrors.IsConflict(err) || count != 3 {
+		t.Fatalf("should stop after max attempts: err = %v, count = %d", err, count)
+	}
+
+	count = 0
+	errNotTransient := errors.New("not transient")
+	_, err = Retry(policy, countedAction(&count, errNotTransient)).Run(context.Background())
+	if err != errNotTransient || count != 1 {
+		t.Fatalf("should not retry on non-transient errors: err = %v, count = %d", err, count)
+	}
+}
+
+func Test_Retry_ContextCancelled(t *testing.T) {
+	var count int
+	policy := RetryPolicy{Backoff: ConstantBackoff(time.Hour), MaxAttempts: 3}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := Retry(policy, countedAction(&count, errConflict, errConflict)).Run(ctx)
+	if !apierrors.IsConflict(err) || count != 1 {
+		t.Fatalf("should give up when context is done: err = %v, count = %d", err, count)
+	}
+}
+
+func Test_Retry_Description(t *testing.T) {
+	policy := RetryPolicy{Backoff: ExponentialBackoff(10*time.Millisecond, 2, time.Second), MaxAttempts: 5}
+	if Retry(policy, Nop).Description() != "Retry(Nop, Exponential(10ms, 2, 1s), 5)" {
+		t.Fatal("description of retry is not correct")
+	}
+
+	if Retry(RetryPolicy{}, Nop).Description() != "Retry(Nop, None, 1)" {
+		t.Fatal("description of retry is not correct")
+	}
+}
+
+func Test_Backoff(t *testing.T) {
+	exp := ExponentialBackoff(10*time.Millisecond, 2, 50*time.Millisecond)
+	for n, expected := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond} {
+		if d := exp.Duration(n + 1); d != expected {
+			t.Fatalf("exponential backoff of retry %d is not correct: %s", n+1, d)
+		}
+	}
+
+	jittered := JitteredBackoff(ConstantBackoff(10*time.Millisecond), 0.5)
+	for n := 1; n <= 10; n++ {
+		if d := jittered.Duration(n); d < 10*time.Millisecond || d >= 15*time.Millisecond {
+			t.Fatalf("jittered backoff out of range: %s", d)
+		}
+	}
+}
@@ -14,12 +14,19 @@ func (act *sequentialActions) Description() string {
 	return describeGroup("Sequential", act.actions...)
 }
 
-func (act *sequentialActions) Run(ctx context.Context) (ctrl.Result, error) {
+func (act *sequentialActions) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
 	// Run actions one-by-one. If one action needs to requeue or requeue after, then the
 	// control flow is broken and control is returned to the outer scope.
-	for _, act := range act.actions {
-		result, err := act.Run(ctx)
+	for i, child := range act.actions {
+		result, err = runChild(ctx, child)
 		if NeedsRequeue(result, err) {
+			// Record the rest as skipped.
+			for _, skipped := range act.actions[i+1:] {
+				skipSpan(ctx, skipped)
+			}
 			return result, err
 		}
 	}
@@ -0,0 +1,164 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ErrNotOwned is returned when syncing an object that's controlled by another owner.
+var ErrNotOwned = errors.New("object not owned by target")
+
+// newObjectOf returns a new empty object of the same type as obj.
+func newObjectOf(obj client.Object) client.Object {
+	return reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+}
+
+// isClusterScoped tells if the object is of a cluster-scoped type by the RESTMapper.
+func isClusterScoped(c client.Client, obj client.Object) (bool, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return false, err
+	}
+	mapping, err := c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameRoot, nil
+}
+
+// defaultNamespace sets the namespace of the owner onto the object if it has none, unless
+// the object is of a cluster-scoped type. Types unknown to the RESTMapper are taken as
+// namespaced ones.
+func defaultNamespace(c client.Client, owner, obj client.Object) error {
+	if obj.GetNamespace() != "" {
+		return nil
+	}
+	if clusterScoped, err := isClusterScoped(c, obj); err != nil && !meta.IsNoMatchError(err) {
+		return fmt.Errorf("unable to get scope: %w", err)
+	} else if !clusterScoped {
+		obj.SetNamespace(owner.GetNamespace())
+	}
+	return nil
+}
+
+// mergeDesired merges the desired values into the current ones. Maps are merged recursively,
+// lists of the same length are merged by the indexes, so that the fields defaulted by the
+// API server in the elements (e.g., the containers of a pod template) are kept, and other
+// values are replaced. Nil desired values are ignored.
+func mergeDesired(current, desired interface{}) interface{} {
+	if desired == nil {
+		return current
+	}
+	switch desired := desired.(type) {
+	case map[string]interface{}:
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return desired
+		}
+		for k, v := range desired {
+			currentMap[k] = mergeDesired(currentMap[k], v)
+		}
+		return currentMap
+	case []interface{}:
+		currentList, ok := current.([]interface{})
+		if !ok || len(currentList) != len(desired) {
+			return desired
+		}
+		for i, v := range desired {
+			currentList[i] = mergeDesired(currentList[i], v)
+		}
+		return currentList
+	default:
+		return desired
+	}
+}
+
+// mergeDesiredObject merges the desired object into the current object. Only the labels and
+// annotations are merged from the metadata, and the status is ignored.
+func mergeDesiredObject(current, desired runtime.Object) (map[string]interface{}, error) {
+	currentU, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	if err != nil {
+		return nil, err
+	}
+	desiredU, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := runtime.DeepCopyJSON(currentU)
+	for k, v := range desiredU {
+		switch k {
+		case "apiVersion", "kind", "status":
+		case "metadata":
+			metadata, _ := v.(map[string]interface{})
+			mergedMetadata, _ := merged["metadata"].(map[string]interface{})
+			for _, field := range []string{"labels", "annotations"} {
+				mergedMetadata[field] = mergeDesired(mergedMetadata[field], metadata[field])
+			}
+		default:
+			merged[k] = mergeDesired(merged[k], v)
+		}
+	}
+	return merged, nil
+}
+
+// SyncOwnedObject creates the desired object with a controller reference to the owner if
+// it's missing. Otherwise, it merges the desired object into the existing one and updates it
+// if they're semantically different, i.e., only the fields set by the desired object are
+// compared, and those defaulted by the API server don't cause updates. Existing objects not controlled by the owner are
+// refused with ErrNotOwned. The desired object is in the namespace of the owner by default,
+// unless it's cluster-scoped, and is updated in place with the object synced.
+func SyncOwnedObject(ctx context.Context, c client.Client, owner, desired client.Object) (controllerutil.OperationResult, error) {
+	if err := defaultNamespace(c, owner, desired); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	current := newObjectOf(desired)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(desired), current); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, fmt.Errorf("unable to get object: %w", err)
+		}
+
+		if err := controllerutil.SetControllerReference(owner, desired, c.Scheme()); err != nil {
+			return controllerutil.OperationResultNone, fmt.Errorf("unable to set controller reference: %w", err)
+		}
+		if err := c.Create(ctx, desired); err != nil {
+			return controllerutil.OperationResultNone, fmt.Errorf("unable to create object: %w", err)
+		}
+		return controllerutil.OperationResultCreated, nil
+	}
+
+	if !ValidateOwnership(current, owner) {
+		return controllerutil.OperationResultNone, fmt.Errorf("unable to sync object %s: %w", client.ObjectKeyFromObject(current), ErrNotOwned)
+	}
+
+	merged, err := mergeDesiredObject(current, desired)
+	if err != nil {
+		return controllerutil.OperationResultNone, fmt.Errorf("unable to merge object: %w", err)
+	}
+	updated := newObjectOf(desired)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(merged, updated); err != nil {
+		return controllerutil.OperationResultNone, fmt.Errorf("unable to merge object: %w", err)
+	}
+
+	op := controllerutil.OperationResultNone
+	if !equality.Semantic.DeepEqual(current, updated) {
+		if err := c.Update(ctx, updated); err != nil {
+			return controllerutil.OperationResultNone, fmt.Errorf("unable to update object: %w", err)
+		}
+		op = controllerutil.OperationResultUpdated
+	}
+
+	reflect.ValueOf(desired).Elem().Set(reflect.ValueOf(updated).Elem())
+	return op, nil
+}
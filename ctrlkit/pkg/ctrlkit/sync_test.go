@@ -0,0 +1,205 @@
+package ctrlkit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func newTestOwner() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "owner",
+			Namespace: "default",
+			UID:       "owner-uid",
+		},
+	}
+}
+
+func newDesiredConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "test",
+			Labels: map[string]string{"app": "test"},
+		},
+		Data: data,
+	}
+}
+
+func newTestNode() *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node",
+			UID:  "node-uid",
+		},
+	}
+}
+
+func newDesiredPV() *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+}
+
+// newTestRESTMapper returns a RESTMapper knowing the scopes of the types used in tests.
+func newTestRESTMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Node"), meta.RESTScopeRoot)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("PersistentVolume"), meta.RESTScopeRoot)
+	return mapper
+}
+
+func Test_SyncOwnedObject(t *testing.T) {
+	owner := newTestOwner()
+	c := fake.NewClientBuilder().WithObjects(owner).Build()
+
+	// Missing, create it.
+	op, err := SyncOwnedObject(context.Background(), c, owner, newDesiredConfigMap(map[string]string{"a": "1"}))
+	if err != nil || op != controllerutil.OperationResultCreated {
+		t.Fatalf("object should be created: op = %s, err = %v", op, err)
+	}
+	cm := getTestConfigMap(t, c)
+	if !ValidateOwnership(cm, owner) {
+		t.Fatal("controller reference is not set")
+	}
+
+	// Unchanged, do nothing.
+	op, err = SyncOwnedObject(context.Background(), c, owner, newDesiredConfigMap(map[string]string{"a": "1"}))
+	if err != nil || op != controllerutil.OperationResultNone {
+		t.Fatalf("object should not be updated: op = %s, err = %v", op, err)
+	}
+
+	// Changed, update it and keep the fields not desired.
+	cm.Labels["extra"] = "true"
+	if err := c.Update(context.Background(), cm); err != nil {
+		t.Fatal(err)
+	}
+	desired := newDesiredConfigMap(map[string]string{"a": "2"})
+	op, err = SyncOwnedObject(context.Background(), c, owner, desired)
+	if err != nil || op != controllerutil.OperationResultUpdated {
+		t.Fatalf("object should be updated: op = %s, err = %v", op, err)
+	}
+	cm = getTestConfigMap(t, c)
+	if cm.Data["a"] != "2" || cm.Labels["extra"] != "true" || cm.Labels["app"] != "test" {
+		t.Fatalf("object is not merged: %v", cm)
+	}
+	if desired.ResourceVersion != cm.ResourceVersion {
+		t.Fatal("desired object should be updated in place")
+	}
+}
+
+func newDesiredJob(image string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{Name: "main", Image: image},
+					},
+				},
+			},
+		},
+	}
+}
+
+func Test_SyncOwnedObject_ServerDefaults(t *testing.T) {
+	owner := newTestOwner()
+
+	// The job created with the fields defaulted by the API server.
+	existing := newDesiredJob("busybox")
+	existing.Namespace = "default"
+	if err := controllerutil.SetControllerReference(owner, existing, scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+	parallelism := int32(1)
+	existing.Spec.Parallelism = &parallelism
+	existing.Spec.Template.Labels = map[string]string{"controller-uid": "job-uid"}
+	existing.Spec.Template.Spec.DNSPolicy = corev1.DNSClusterFirst
+	existing.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+	existing.Spec.Template.Spec.Containers[0].TerminationMessagePath = corev1.TerminationMessagePathDefault
+	existing.Spec.Template.Spec.Containers[0].Resources = corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	}
+	c := fake.NewClientBuilder().WithObjects(owner, existing).Build()
+
+	// Unchanged except the defaulted fields, do nothing.
+	op, err := SyncOwnedObject(context.Background(), c, owner, newDesiredJob("busybox"))
+	if err != nil || op != controllerutil.OperationResultNone {
+		t.Fatalf("object should not be updated: op = %s, err = %v", op, err)
+	}
+
+	// Changed, update it and keep the defaulted fields.
+	op, err = SyncOwnedObject(context.Background(), c, owner, newDesiredJob("alpine"))
+	if err != nil || op != controllerutil.OperationResultUpdated {
+		t.Fatalf("object should be updated: op = %s, err = %v", op, err)
+	}
+	var job batchv1.Job
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(existing), &job); err != nil {
+		t.Fatal(err)
+	}
+	container := job.Spec.Template.Spec.Containers[0]
+	if container.Image != "alpine" || container.TerminationMessagePath != corev1.TerminationMessagePathDefault ||
+		container.ImagePullPolicy != corev1.PullIfNotPresent || container.Resources.Limits.Cpu().String() != "1" ||
+		job.Spec.Template.Labels["controller-uid"] != "job-uid" || job.Spec.Parallelism == nil {
+		t.Fatalf("defaulted fields should be kept: %v", job)
+	}
+}
+
+func Test_SyncOwnedObject_NotOwned(t *testing.T) {
+	owner := newTestOwner()
+	existing := newDesiredConfigMap(map[string]string{"a": "1"})
+	existing.Namespace = "default"
+	c := fake.NewClientBuilder().WithObjects(owner, existing).Build()
+
+	_, err := SyncOwnedObject(context.Background(), c, owner, newDesiredConfigMap(map[string]string{"a": "2"}))
+	if !errors.Is(err, ErrNotOwned) {
+		t.Fatalf("object not owned should be refused: %v", err)
+	}
+
+	var cm corev1.ConfigMap
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(existing), &cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["a"] != "1" {
+		t.Fatal("object not owned should not be touched")
+	}
+}
+
+func Test_SyncOwnedObject_ClusterScoped(t *testing.T) {
+	c := fake.NewClientBuilder().WithRESTMapper(newTestRESTMapper()).Build()
+
+	// Cluster-scoped objects are not put into the namespace of the owner.
+	desired := newDesiredPV()
+	if _, err := SyncOwnedObject(context.Background(), c, newTestOwner(), desired); err == nil {
+		t.Fatal("cluster-scoped object can't be owned by a namespaced owner")
+	}
+	if desired.Namespace != "" {
+		t.Fatalf("cluster-scoped object should have no namespace: %s", desired.Namespace)
+	}
+
+	op, err := SyncOwnedObject(context.Background(), c, newTestNode(), newDesiredPV())
+	if err != nil || op != controllerutil.OperationResultCreated {
+		t.Fatalf("object should be created: op = %s, err = %v", op, err)
+	}
+	var pv corev1.PersistentVolume
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "test"}, &pv); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -17,7 +17,10 @@ func (act *timeoutAction) Description() string {
 	return fmt.Sprintf("Timeout(%s, %s)", act.inner.Description(), act.timeout)
 }
 
-func (act *timeoutAction) Run(ctx context.Context) (ctrl.Result, error) {
+func (act *timeoutAction) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, act)
+	defer endSpan(span, act, &result, &err)
+
 	ctx, cancel := context.WithTimeout(ctx, act.timeout)
 	defer cancel()
 
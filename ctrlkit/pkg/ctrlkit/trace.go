@@ -0,0 +1,221 @@
+package ctrlkit
+
+import (
+	"context"
+	"encoding/json"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Kinds of the actions reported to the tracers.
+const (
+	KindAction       = "Action"
+	KindNop          = "Nop"
+	KindSequential   = "Sequential"
+	KindJoin         = "Join"
+	KindParallelJoin = "ParallelJoin"
+	KindParallel     = "Parallel"
+	KindTimeout      = "Timeout"
+	KindRetry        = "Retry"
+	KindRecover      = "Recover"
+	KindWhen         = "When"
+	KindUnless       = "Unless"
+	KindIfElse       = "IfElse"
+	KindSwitch       = "Switch"
+	KindUntil        = "Until"
+	KindRequeueUntil = "RequeueUntil"
+	KindFinalizer    = "Finalizer"
+)
+
+// Tracer traces the runs of actions. It's opt-in and carried on the context, see WithTracer.
+type Tracer interface {
+	// Start starts a span for a run of the action. The returned context carries the span
+	// so that the spans started with it are its children.
+	Start(ctx context.Context, kind string, act ReconcileAction) (context.Context, Span)
+
+	// Skip records that the action is skipped, e.g., when an earlier action in Sequential
+	// requires a requeue.
+	Skip(ctx context.Context, kind string, act ReconcileAction)
+}
+
+// Span is a span started by Tracer.
+type Span interface {
+	// End ends the span with the result and error of the run.
+	End(result ctrl.Result, err error)
+}
+
+type tracerKey struct{}
+
+// WithTracer returns a copy of the context carrying the tracer. Actions run with the context
+// are traced.
+func WithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// TracerFromContext returns the tracer carried on the context, or nil if there's none.
+func TracerFromContext(ctx context.Context) Tracer {
+	tracer, _ := ctx.Value(tracerKey{}).(Tracer)
+	return tracer
+}
+
+func kindOf(act ReconcileAction) string {
+	switch act := act.(type) {
+	case *nopAction:
+		return KindNop
+	case *sequentialActions:
+		return KindSequential
+	case *joinAction:
+		if act.runner.IsParallel() {
+			return KindParallelJoin
+		}
+		return KindJoin
+	case *parallelAction:
+		return KindParallel
+	case *timeoutAction:
+		return KindTimeout
+	case *retryAction:
+		return KindRetry
+	case *recoverAction:
+		return KindRecover
+	case *conditionalAction:
+		return act.head
+	case *switchAction:
+		return KindSwitch
+	case *untilAction:
+		return KindUntil
+	case *requeueUntilAction:
+		return KindRequeueUntil
+	case *finalizerAction:
+		return KindFinalizer
+	default:
+		return KindAction
+	}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) End(result ctrl.Result, err error) {}
+
+// startSpan starts a span of the action if there's a tracer on the context.
+func startSpan(ctx context.Context, act ReconcileAction) (context.Context, Span) {
+	tracer := TracerFromContext(ctx)
+	if tracer == nil {
+		return ctx, nopSpan{}
+	}
+	return tracer.Start(ctx, kindOf(act), act)
+}
+
+// endSpan ends the span with the result and error of the run. It must be deferred, so that
+// a panic of the run ends the span with a *PanicError before it's raised again.
+func endSpan(span Span, act ReconcileAction, result *ctrl.Result, err *error) {
+	if r := recover(); r != nil {
+		span.End(ctrl.Result{}, &PanicError{
+			Action: act.Description(),
+			Value:  r,
+			Stack:  debug.Stack(),
+		})
+		panic(r)
+	}
+	span.End(*result, *err)
+}
+
+// skipSpan records the skipped action if there's a tracer on the context.
+func skipSpan(ctx context.Context, act ReconcileAction) {
+	if tracer := TracerFromContext(ctx); tracer != nil {
+		tracer.Skip(ctx, kindOf(act), act)
+	}
+}
+
+// TraceSpan is a span recorded by TraceRecorder.
+type TraceSpan struct {
+	Kind        string       `json:"kind"`
+	Description string       `json:"description"`
+	StartTime   time.Time    `json:"start_time"`
+	EndTime     time.Time    `json:"end_time"`
+	Result      ctrl.Result  `json:"result"`
+	Err         error        `json:"-"`
+	Error       string       `json:"error,omitempty"`
+	Skipped     bool         `json:"skipped,omitempty"`
+	Children    []*TraceSpan `json:"children,omitempty"`
+
+	recorder *TraceRecorder
+}
+
+func (s *TraceSpan) End(result ctrl.Result, err error) {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+
+	s.EndTime = time.Now()
+	s.Result = result
+	s.Err = err
+	if err != nil {
+		s.Error = err.Error()
+	}
+}
+
+type traceSpanKey struct{}
+
+// TraceRecorder is a Tracer recording the spans into trees in memory, which follow the
+// structure of the actions and could be exported as JSON.
+type TraceRecorder struct {
+	mu    sync.Mutex
+	roots []*TraceSpan
+}
+
+func (r *TraceRecorder) addSpan(ctx context.Context, span *TraceSpan) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if parent, ok := ctx.Value(traceSpanKey{}).(*TraceSpan); ok && parent.recorder == r {
+		parent.Children = append(parent.Children, span)
+	} else {
+		r.roots = append(r.roots, span)
+	}
+}
+
+func (r *TraceRecorder) Start(ctx context.Context, kind string, act ReconcileAction) (context.Context, Span) {
+	span := &TraceSpan{
+		Kind:        kind,
+		Description: act.Description(),
+		StartTime:   time.Now(),
+		recorder:    r,
+	}
+	r.addSpan(ctx, span)
+	return context.WithValue(ctx, traceSpanKey{}, span), span
+}
+
+func (r *TraceRecorder) Skip(ctx context.Context, kind string, act ReconcileAction) {
+	r.addSpan(ctx, &TraceSpan{
+		Kind:        kind,
+		Description: act.Description(),
+		Skipped:     true,
+		recorder:    r,
+	})
+}
+
+// Spans returns the root spans recorded.
+func (r *TraceRecorder) Spans() []*TraceSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*TraceSpan(nil), r.roots...)
+}
+
+// MarshalJSON exports the root spans recorded as a JSON array.
+func (r *TraceRecorder) MarshalJSON() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.roots == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(r.roots)
+}
+
+// NewTraceRecorder returns a new TraceRecorder.
+func NewTraceRecorder() *TraceRecorder {
+	return &TraceRecorder{}
+}
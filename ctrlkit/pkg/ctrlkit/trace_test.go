@@ -0,0 +1,137 @@
+package ctrlkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func namedAction(name string, result ctrl.Result, err error) ReconcileAction {
+	return WrapAction(name, func(ctx context.Context) (ctrl.Result, error) {
+		return result, err
+	})
+}
+
+func Test_TraceRecorder_Sequential(t *testing.T) {
+	recorder := NewTraceRecorder()
+	ctx := WithTracer(context.Background(), recorder)
+
+	act := Sequential(
+		namedAction("A", ctrl.Result{}, nil),
+		namedAction("B", ctrl.Result{RequeueAfter: time.Second}, nil),
+		namedAction("C", ctrl.Result{}, nil),
+		namedAction("D", ctrl.Result{}, nil),
+	)
+	if _, err := act.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Spans()
+	if len(spans) != 1 || spans[0].Kind != KindSequential || spans[0].Result.RequeueAfter != time.Second {
+		t.Fatal("root span is not correct")
+	}
+
+	children := spans[0].Children
+	if len(children) != 4 {
+		t.Fatalf("children spans are not correct: %d", len(children))
+	}
+	for i, expected := range []struct {
+		description string
+		skipped     bool
+	}{{"A", false}, {"B", false}, {"C", true}, {"D", true}} {
+		span := children[i]
+		if span.Kind != KindAction || span.Description != expected.description || span.Skipped != expected.skipped {
+			t.Fatalf("span %d is not correct: %+v", i, span)
+		}
+		if !span.Skipped && (span.StartTime.IsZero() || span.EndTime.Before(span.StartTime)) {
+			t.Fatalf("time of span %d is not correct", i)
+		}
+	}
+}
+
+func Test_TraceRecorder_Tree(t *testing.T) {
+	recorder := NewTraceRecorder()
+	ctx := WithTracer(context.Background(), recorder)
+
+	errFailed := errors.New("failed")
+	act := JoinOrdered(
+		Timeout(time.Second, namedAction("A", ctrl.Result{}, errFailed)),
+		JoinInParallel(Nop, namedAction("B", ctrl.Result{Requeue: true}, nil)),
+	)
+	if _, err := act.Run(ctx); err != errFailed {
+		t.Fatal("error of join is not correct")
+	}
+
+	b, err := json.Marshal(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spans []struct {
+		Kind     string `json:"kind"`
+		Error    string `json:"error"`
+		Children []struct {
+			Kind     string `json:"kind"`
+			Children []struct {
+				Kind        string `json:"kind"`
+				Description string `json:"description"`
+			} `json:"children"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal(b, &spans); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spans) != 1 || spans[0].Kind != KindJoin || spans[0].Error != "failed" {
+		t.Fatalf("root span is not correct: %s", b)
+	}
+	children := spans[0].Children
+	if len(children) != 2 || children[0].Kind != KindTimeout || children[1].Kind != KindParallelJoin {
+		t.Fatalf("children spans are not correct: %s", b)
+	}
+	if len(children[0].Children) != 1 || children[0].Children[0].Description != "A" {
+		t.Fatalf("spans of timeout are not correct: %s", b)
+	}
+	if len(children[1].Children) != 2 {
+		t.Fatalf("spans of parallel join are not correct: %s", b)
+	}
+}
+
+func Test_TraceRecorder_Panic(t *testing.T) {
+	recorder := NewTraceRecorder()
+	ctx := WithTracer(context.Background(), recorder)
+
+	timeout := Timeout(time.Second, panicAction)
+	_, err := Sequential(Nop, timeout).Run(ctx)
+	assertPanicError(t, err, timeout.Description())
+
+	// Spans of the panicking actions end with the panic error, not success.
+	spans := recorder.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("root spans are not correct: %d", len(spans))
+	}
+	for span := spans[0]; ; span = span.Children[len(span.Children)-1] {
+		var panicErr *PanicError
+		if !errors.As(span.Err, &panicErr) || panicErr.Value != "boom" {
+			t.Fatalf("span %s should end with the panic: %v", span.Description, span.Err)
+		}
+		if len(span.Children) == 0 {
+			break
+		}
+	}
+}
+
+func Test_TraceRecorder_Disabled(t *testing.T) {
+	if TracerFromContext(context.Background()) != nil {
+		t.Fatal("tracer should be nil")
+	}
+
+	b, err := json.Marshal(NewTraceRecorder())
+	if err != nil || string(b) != "[]" {
+		t.Fatal("empty recorder should be exported as an empty array")
+	}
+}
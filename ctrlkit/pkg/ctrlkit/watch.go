@@ -0,0 +1,46 @@
+package ctrlkit
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// EnqueueRequestsByLabel returns an event handler enqueuing the request of the object named
+// by the value of the label, in the same namespace of the object. Objects without the label
+// are ignored.
+func EnqueueRequestsByLabel(label string) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		name, ok := obj.GetLabels()[label]
+		if !ok || name == "" {
+			return nil
+		}
+		return []reconcile.Request{
+			{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}},
+		}
+	})
+}
+
+// EnqueueRequestsByLabels returns an event handler enqueuing the request of the object
+// namespaced and named by the values of the labels, e.g., for objects in other namespaces
+// than the target's. An empty namespaceLabel enqueues the requests of cluster-scoped
+// objects. Objects without the labels are ignored.
+func EnqueueRequestsByLabels(namespaceLabel, nameLabel string) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		labels := obj.GetLabels()
+		name, ok := labels[nameLabel]
+		if !ok || name == "" {
+			return nil
+		}
+		var namespace string
+		if namespaceLabel != "" {
+			if namespace = labels[namespaceLabel]; namespace == "" {
+				return nil
+			}
+		}
+		return []reconcile.Request{
+			{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}},
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package ctrlkit
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_EnqueueRequestsByLabel(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+
+	h := EnqueueRequestsByLabel("app")
+	h.Create(event.CreateEvent{Object: &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: "default"},
+	}}, queue)
+	h.Create(event.CreateEvent{Object: &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: map[string]string{"app": "example"}},
+	}}, queue)
+
+	if queue.Len() != 1 {
+		t.Fatalf("only labeled objects should be enqueued: %d", queue.Len())
+	}
+	item, _ := queue.Get()
+	if item != (reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "example"}}) {
+		t.Fatalf("request is not correct: %v", item)
+	}
+}
+
+func Test_EnqueueRequestsByLabels(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+
+	h := EnqueueRequestsByLabels("app-namespace", "app")
+	h.Create(event.CreateEvent{Object: &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: "default", Labels: map[string]string{"app": "example"}},
+	}}, queue)
+	h.Create(event.CreateEvent{Object: &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: map[string]string{
+			"app":           "example",
+			"app-namespace": "system",
+		}},
+	}}, queue)
+
+	if queue.Len() != 1 {
+		t.Fatalf("only labeled objects should be enqueued: %d", queue.Len())
+	}
+	item, _ := queue.Get()
+	if item != (reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "system", Name: "example"}}) {
+		t.Fatalf("request is not correct: %v", item)
+	}
+	queue.Done(item)
+
+	EnqueueRequestsByLabels("", "app").Create(event.CreateEvent{Object: &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: map[string]string{"app": "example"}},
+	}}, queue)
+	item, _ = queue.Get()
+	if item != (reconcile.Request{NamespacedName: types.NamespacedName{Name: "example"}}) {
+		t.Fatalf("request of cluster-scoped object is not correct: %v", item)
+	}
+}
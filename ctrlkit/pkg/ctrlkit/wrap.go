@@ -17,7 +17,10 @@ func (w *actionWrapper) Description() string {
 	return w.description
 }
 
-func (w *actionWrapper) Run(ctx context.Context) (ctrl.Result, error) {
+func (w *actionWrapper) Run(ctx context.Context) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, w)
+	defer endSpan(span, w, &result, &err)
+
 	return w.actionFunc(ctx)
 }
 
@@ -7,9 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 var CtrlKitPackage = "github.com/arkbriar/ctrlkit/pkg/ctrlkit"
@@ -50,12 +53,16 @@ func generateImports(doc *ControllerManagerDocument) ([]string, error) {
 		"context":                            "",
 		"errors":                             "",
 		"fmt":                                "",
+		"time":                               "",
 		CtrlKitPackage:                       "",
+		"github.com/go-logr/logr":            "",
 		"k8s.io/apimachinery/pkg/api/errors": "apierrors",
 		"k8s.io/apimachinery/pkg/types":      "",
 		"k8s.io/apimachinery/pkg/runtime":    "",
-		"sigs.k8s.io/controller-runtime/pkg/client": "",
-		"sigs.k8s.io/controller-runtime":            "ctrl",
+		"sigs.k8s.io/controller-runtime/pkg/client":    "",
+		"sigs.k8s.io/controller-runtime/pkg/reconcile": "",
+		"sigs.k8s.io/controller-runtime/pkg/source":    "",
+		"sigs.k8s.io/controller-runtime":               "ctrl",
 	}
 
 	// Add each binds into the imports.
@@ -78,20 +85,59 @@ func generateImports(doc *ControllerManagerDocument) ([]string, error) {
 
 const managerStateGoTemplate = `// %sState is the state manager of %s.
 type %sState struct {
-	client.Reader
+	%s
 	target *%s
 }
 %s
 
 // New%sState returns a %sState (target is not copied).
-func New%sState(reader client.Reader, target *%s) %sState {
+func New%sState(%s %s, target *%s) %sState {
 	return %sState{
-		Reader: reader,
+		%s: %s,
 		target: target,
 	}
 }
 `
 
+// needsWriter tells if the generated codes of the manager write objects, which requires
+// the state to hold a client.Client rather than a client.Reader.
+func needsWriter(mgr *ControllerManagerDeclaration) bool {
+	return mgr.Finalizer != nil || len(builderStates(mgr)) > 0 || len(prunerStates(mgr)) > 0
+}
+
+// builderStates returns the states declaring a desired-object builder, sorted by name.
+func builderStates(mgr *ControllerManagerDeclaration) []StateDeclaration {
+	states := lo.Filter(lo.Values(mgr.States), func(s StateDeclaration, _ int) bool {
+		_, ok := s.Builder()
+		return ok
+	})
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+// prunerStates returns the states declaring a pruner, sorted by name.
+func prunerStates(mgr *ControllerManagerDeclaration) []StateDeclaration {
+	states := lo.Filter(lo.Values(mgr.States), func(s StateDeclaration, _ int) bool {
+		_, ok := s.Pruner()
+		return ok
+	})
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+// stateClientTypeAndField returns the type of the client embedded in state, its field name
+// and the name of the parameter in the constructor.
+func stateClientTypeAndField(mgr *ControllerManagerDeclaration) (typ, field, param string) {
+	if needsWriter(mgr) {
+		return "client.Client", "Client", "c"
+	}
+	return "client.Reader", "Reader", "reader"
+}
+
 func formatIntoStateGoCode(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) (string, error) {
 	bodyBuf := &bytes.Buffer{}
 
@@ -116,24 +162,29 @@ func formatIntoStateGoCode(doc *ControllerManagerDocument, mgr *ControllerManage
 	typeBind := doc.GvPkgBinds[gvk.GroupVersion().String()]
 	targetGoType := constructPkgAliasForGvPkg(typeBind) + "." + gvk.Kind
 
+	clientType, clientField, clientParam := stateClientTypeAndField(mgr)
+
 	return fmt.Sprintf(managerStateGoTemplate,
 		mgr.Name, mgr.Name,
 		mgr.Name,
+		clientType,
 		targetGoType,
 		bodyBuf.String(),
 		mgr.Name, mgr.Name,
-		mgr.Name, targetGoType, mgr.Name, mgr.Name,
+		mgr.Name, clientParam, clientType, targetGoType, mgr.Name,
+		mgr.Name,
+		clientField, clientParam,
 	), nil
 }
 
-func formatSelectorsIntoComments(selectors map[string]string) string {
+func formatSelectorsIntoComments(state *StateDeclaration) string {
 	buf := &bytes.Buffer{}
 
-	sortedKeys := lo.Keys(selectors)
+	sortedKeys := lo.Keys(state.Selectors)
 	sort.Strings(sortedKeys)
 
 	for _, k := range sortedKeys {
-		v := selectors[k]
+		v := state.Selectors[k]
 		buf.WriteString("//   + ")
 		buf.WriteString(k)
 		if v != "" {
@@ -142,6 +193,11 @@ func formatSelectorsIntoComments(selectors map[string]string) string {
 		}
 		buf.WriteString("\n")
 	}
+	for _, r := range state.LabelRequirements {
+		buf.WriteString("//   + labels/")
+		buf.WriteString(r)
+		buf.WriteString("\n")
+	}
 
 	s := buf.String()
 	return s[:len(s)-1]
@@ -152,8 +208,7 @@ const (
 func (s *%sState) Get%s(ctx context.Context) (*%s, error) {
 	var %s %s
 
-	err := s.Get(ctx, types.NamespacedName{
-		Namespace: s.target.Namespace,
+	err := s.Get(ctx, types.NamespacedName{%s
 		Name: %s,
 	}, &%s)
 	if err != nil {
@@ -177,8 +232,8 @@ func (s *%sState) Get%s(ctx context.Context) (*%s, error) {
 %s
 	}
 
-	err := s.List(ctx, &%sList, client.InNamespace(s.target.Namespace), 
-		client.MatchingLabels(matchingLabels))
+	err := s.List(ctx, &%sList, %s
+		%s%s)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get state '%s': %%w", err)
 	}
@@ -206,8 +261,8 @@ func (s *%sState) Get%s(ctx context.Context) ([]%s, error) {
 %s
 	}
 
-	err := s.List(ctx, &%sList, client.InNamespace(s.target.Namespace), 
-		client.MatchingLabels(matchingLabels))
+	err := s.List(ctx, &%sList, %s
+		%s%s)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get state '%s': %%w", err)
 	}
@@ -281,6 +336,27 @@ func getStrExpr(expr string, targetStub string) (string, error) {
 	return strings.Join(exprs, " + "), nil
 }
 
+// getStateNamespaceExpr returns the namespace expression of the state, and whether the
+// state is in a single namespace.
+func getStateNamespaceExpr(state *StateDeclaration, targetStub string) (string, bool, error) {
+	namespace, namespaced := state.Namespace()
+	if !namespaced {
+		return "", false, nil
+	}
+	namespaceExpr, err := getStrExpr(namespace, targetStub)
+	return namespaceExpr, true, err
+}
+
+// generateInNamespaceOption generates the client.InNamespace option of the list call, or an
+// empty string if the state isn't in a single namespace.
+func generateInNamespaceOption(state *StateDeclaration) (string, error) {
+	namespaceExpr, namespaced, err := getStateNamespaceExpr(state, "s.target")
+	if err != nil || !namespaced {
+		return "", err
+	}
+	return "client.InNamespace(" + namespaceExpr + "), ", nil
+}
+
 func getStateNameExpr(state *StateDeclaration) (string, error) {
 	nameSelector := state.Selectors["name"]
 	return getStrExpr(nameSelector, "s.target")
@@ -356,6 +432,16 @@ func generateMatchingLabels(state *StateDeclaration, indent string) (string, err
 	return generateMapKeyAndUnquotedValuesInGo(labels, indent), nil
 }
 
+// generateMatchingLabelsOption generates the label option of the list call. The set-based
+// requirements are compiled into a selector together with the matching labels.
+func generateMatchingLabelsOption(state *StateDeclaration) string {
+	if len(state.LabelRequirements) == 0 {
+		return "client.MatchingLabels(matchingLabels)"
+	}
+	return fmt.Sprintf("client.MatchingLabelsSelector{\n\t\t\tSelector: ctrlkit.LabelSelector(matchingLabels, ctrlkit.MustParseLabelRequirements(%s)...),\n\t\t}",
+		strconv.Quote(strings.Join(state.LabelRequirements, ",")))
+}
+
 func generateMatchingFields(state *StateDeclaration, indent string) (string, error) {
 	labels := make(map[string]string)
 	for k, v := range state.Selectors {
@@ -370,6 +456,16 @@ func generateMatchingFields(state *StateDeclaration, indent string) (string, err
 	return generateMapKeyAndUnquotedValuesInGo(labels, indent), nil
 }
 
+// generateMatchingFieldsOption generates the client.MatchingFields option of the list call,
+// or an empty string if there are no field selectors.
+func generateMatchingFieldsOption(state *StateDeclaration) (string, error) {
+	matchingFields, err := generateMatchingFields(state, "\t\t\t")
+	if err != nil || matchingFields == "" {
+		return "", err
+	}
+	return ",\n\t\tclient.MatchingFields{\n" + matchingFields + "\n\t\t}", nil
+}
+
 func indentStr(s string, indent string) string {
 	return strings.Join(lo.Map(strings.Split(s, "\n"), func(s string, i int) string {
 		return indent + s
@@ -411,6 +507,12 @@ func generateGetStateCodes(doc *ControllerManagerDocument, mgr *ControllerManage
 	if err != nil {
 		return "", err
 	}
+	namespaceField := ""
+	if namespaceExpr, namespaced, err := getStateNamespaceExpr(state, "s.target"); err != nil {
+		return "", err
+	} else if namespaced {
+		namespaceField = "\n\t\tNamespace: " + namespaceExpr + ","
+	}
 	ownershipCheck := ""
 	if _, ok := state.Selectors["owned"]; ok {
 		ownershipCheck = generateCheckOwnership(stateVarName, "",
@@ -422,6 +524,7 @@ func generateGetStateCodes(doc *ControllerManagerDocument, mgr *ControllerManage
 		upperTheFirstCharInWord(state.Name), state.Name, state.Selectors["name"],
 		mgr.Name, upperTheFirstCharInWord(state.Name), stateGoType,
 		stateVarName, stateGoType,
+		namespaceField,
 		nameExpr,
 		stateVarName,
 		state.Name,
@@ -445,10 +548,14 @@ func generateGetStateByListCodes(doc *ControllerManagerDocument, mgr *Controller
 	if err != nil {
 		return "", err
 	}
-	// matchingFields, err := generateMatchingFields(state, "\t\t")
-	// if err != nil {
-	// 	return "", err
-	// }
+	matchingFields, err := generateMatchingFieldsOption(state)
+	if err != nil {
+		return "", err
+	}
+	inNamespace, err := generateInNamespaceOption(state)
+	if err != nil {
+		return "", err
+	}
 
 	ownershipCheck := ""
 	if _, ok := state.Selectors["owned"]; ok {
@@ -459,15 +566,14 @@ func generateGetStateByListCodes(doc *ControllerManagerDocument, mgr *Controller
 
 	return fmt.Sprintf(managerStateMethodGetByListTemplate,
 		upperTheFirstCharInWord(state.Name), state.Name,
-		formatSelectorsIntoComments(state.Selectors),
+		formatSelectorsIntoComments(state),
 		mgr.Name,
 		upperTheFirstCharInWord(state.Name),
 		stateGoType,
 		stateVarName,
 		stateGoType,
 		matchingLabels,
-		// matchingFields,
-		stateVarName,
+		stateVarName, inNamespace, generateMatchingLabelsOption(state), matchingFields,
 		state.Name,
 		stateVarName,
 		stateVarName,
@@ -493,13 +599,17 @@ func generateListStateCodes(doc *ControllerManagerDocument, mgr *ControllerManag
 	if err != nil {
 		return "", err
 	}
-	// matchingFields, err := generateMatchingFields(state, "\t\t")
-	// if err != nil {
-	// 	return "", err
-	// }
+	matchingFields, err := generateMatchingFieldsOption(state)
+	if err != nil {
+		return "", err
+	}
+	inNamespace, err := generateInNamespaceOption(state)
+	if err != nil {
+		return "", err
+	}
 
-	// FIXME: Ownership check is an optional but the template requires it.
-	ownershipCheck := ""
+	// Objects not owned are all valid, e.g., the ones in other namespaces.
+	ownershipCheck := "\t\tvalidated = append(validated, obj)"
 	if _, ok := state.Selectors["owned"]; ok {
 		ownershipCheck = generateCheckOwnership("obj", "validated = append(validated, obj)", "", "\t")
 		ownershipCheck = indentStr(ownershipCheck, "\t\t")
@@ -507,12 +617,11 @@ func generateListStateCodes(doc *ControllerManagerDocument, mgr *ControllerManag
 
 	return fmt.Sprintf(managerStateMethodListTemplate,
 		upperTheFirstCharInWord(state.Name), state.Name,
-		formatSelectorsIntoComments(state.Selectors),
+		formatSelectorsIntoComments(state),
 		mgr.Name, upperTheFirstCharInWord(state.Name), stateGoType,
 		stateVarName, stateGoType,
 		matchingLabels,
-		// matchingFields,
-		stateVarName,
+		stateVarName, inNamespace, generateMatchingLabelsOption(state), matchingFields,
 		state.Name,
 		stateGoType,
 		stateVarName,
@@ -557,6 +666,7 @@ type %s struct {
 // NewAction returns a new action controlled by the manager.
 func (m *%s) NewAction(description string, f func(context.Context, logr.Logger) (ctrl.Result, error)) ctrlkit.Action {
 	return ctrlkit.NewAction(description, func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = ctrlkit.StartActionRun(ctx)
 		logger := m.logger.WithValues("action", description)
 
 		if m.hook != nil {
@@ -568,6 +678,17 @@ func (m *%s) NewAction(description string, f func(context.Context, logr.Logger)
 	})
 }
 
+// failToGetStates reports the error of getting the states of the action to the hook, and
+// requeues. The hook runs with no states.
+func (m *%s) failToGetStates(ctx context.Context, logger logr.Logger, action string, err error) (ctrl.Result, error) {
+	result, err := ctrlkit.RequeueIfError(err)
+	if m.hook != nil {
+		m.hook.PreRun(ctx, logger, action, nil)
+		m.hook.PostRun(ctx, logger, action, result, err)
+	}
+	return result, err
+}
+
 %s
 
 type %sOption func(*%s)
@@ -613,28 +734,107 @@ func getParamRefType(doc *ControllerManagerDocument, mgr *ControllerManagerDecla
 	}
 }
 
-func generateImplInterfaceDecl(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) (string, error) {
-	methods := make([]string, 0, len(mgr.Actions))
+// implParam is a state parameter of an impl method.
+type implParam struct {
+	name string
+	typ  string
+}
+
+// implMethod is a method of the impl interface. Every method takes the context and the logger
+// before the params, and returns an error after the result.
+type implMethod struct {
+	comments []string
+	name     string
+	params   []implParam
+	result   string
+}
+
+func implMethods(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) ([]implMethod, error) {
+	methods := make([]implMethod, 0, len(mgr.Actions))
 
 	for _, act := range mgr.Actions {
-		method := ""
-		if len(act.Comments) > 0 {
-			method += strings.Join(lo.Map(act.Comments, func(s string, _ int) string {
-				return "\t// " + s
-			}), "\n") + "\n"
-		}
-		params := make([]string, 0, len(act.Params)+1)
-		params = append(params, "ctx context.Context", "logger logr.Logger")
+		params := make([]implParam, 0, len(act.Params))
 		for _, param := range act.Params {
 			paramType, err := getParamRefType(doc, mgr, param)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
+			params = append(params, implParam{name: param, typ: paramType})
+		}
+		methods = append(methods, implMethod{
+			comments: act.Comments,
+			name:     act.Name,
+			params:   params,
+			result:   "ctrl.Result",
+		})
+	}
+
+	for _, state := range builderStates(mgr) {
+		builder, _ := state.Builder()
+		paramType, err := getParamRefType(doc, mgr, state.Name)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, implMethod{
+			comments: []string{fmt.Sprintf("%s builds the desired object of state \"%s\".", builder, state.Name)},
+			name:     builder,
+			result:   paramType,
+		})
+	}
 
-			params = append(params, param+" "+paramType)
+	for _, state := range prunerStates(mgr) {
+		pruner, _ := state.Pruner()
+		paramType, err := getParamRefType(doc, mgr, state.Name)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, implMethod{
+			comments: []string{fmt.Sprintf("%s returns the names of the desired objects of state \"%s\", others are pruned.", pruner, state.Name)},
+			name:     pruner,
+			params:   []implParam{{name: state.Name, typ: paramType}},
+			result:   "[]string",
+		})
+	}
+
+	if mgr.Workflow != nil {
+		for _, predicate := range mgr.Workflow.Predicates() {
+			methods = append(methods, implMethod{
+				comments: []string{fmt.Sprintf("%s is the predicate \"%s\" of the workflow.", predicate, predicate)},
+				name:     predicate,
+				result:   "bool",
+			})
 		}
-		method += "\t" + fmt.Sprintf("%s(%s) (ctrl.Result, error)", act.Name, strings.Join(params, ", "))
+	}
 
+	return methods, nil
+}
+
+// signature returns the params and results of the method, e.g.,
+// "(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error)".
+func (m *implMethod) signature() string {
+	params := make([]string, 0, len(m.params)+2)
+	params = append(params, "ctx context.Context", "logger logr.Logger")
+	for _, param := range m.params {
+		params = append(params, param.name+" "+param.typ)
+	}
+	return fmt.Sprintf("(%s) (%s, error)", strings.Join(params, ", "), m.result)
+}
+
+func generateImplInterfaceDecl(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) (string, error) {
+	implMethods, err := implMethods(doc, mgr)
+	if err != nil {
+		return "", err
+	}
+
+	methods := make([]string, 0, len(implMethods))
+	for _, m := range implMethods {
+		method := ""
+		if len(m.comments) > 0 {
+			method += strings.Join(lo.Map(m.comments, func(s string, _ int) string {
+				return "\t// " + s
+			}), "\n") + "\n"
+		}
+		method += "\t" + m.name + m.signature()
 		methods = append(methods, method)
 	}
 
@@ -655,10 +855,11 @@ func actionNameConst(mgr *ControllerManagerDeclaration, act *ActionDeclaration)
 
 func generateMgrMethodBody(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration, act *ActionDeclaration) string {
 	const errHandleCode = `if err != nil {
-	return ctrlkit.RequeueIfError(err)
+	return m.failToGetStates(ctx, logger, %s, err)
 }`
 	buf := bytes.Buffer{}
 
+	buf.WriteString("ctx = ctrlkit.StartActionRun(ctx)\n")
 	buf.WriteString(fmt.Sprintf("logger := m.logger.WithValues(\"action\", %s)\n\n", actionNameConst(mgr, act)))
 
 	if len(act.Params) > 0 {
@@ -670,7 +871,7 @@ func generateMgrMethodBody(doc *ControllerManagerDocument, mgr *ControllerManage
 			buf.WriteString("m.state.Get")
 			buf.WriteString(upperTheFirstCharInWord(param))
 			buf.WriteString("(ctx)\n")
-			buf.WriteString(errHandleCode)
+			buf.WriteString(fmt.Sprintf(errHandleCode, actionNameConst(mgr, act)))
 			buf.WriteString("\n\n")
 		}
 
@@ -736,10 +937,611 @@ func generateMgrMethods(doc *ControllerManagerDocument, mgr *ControllerManagerDe
 	return strings.Join(methods, "\n"), nil
 }
 
+const (
+	mgrSyncTemplate = `// %s generates the action syncing state "%s" to the desired object built by %s.
+%s
+func (m *%s) %s() ctrlkit.Action {
+	return ctrlkit.NewAction(%s, func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = ctrlkit.StartActionRun(ctx)
+		logger := m.logger.WithValues("action", %s)
+
+		// Invoke action.
+		if m.hook != nil {
+			defer func() { m.hook.PostRun(ctx, logger, %s, result, err) }()
+			m.hook.PreRun(ctx, logger, %s, nil)
+		}
+
+		// Build the desired object.
+		desired, err := m.impl.%s(ctx, logger)
+		if err != nil {
+			return ctrlkit.RequeueIfError(err)
+		}
+%s
+
+%s
+
+		return ctrlkit.NoRequeue()
+	})
+}
+`
+
+	syncByUpdateComments = `// It creates the object with a controller reference to the target if it's missing, and
+// updates it when it differs from the desired one. Objects owned by others are refused.`
+
+	syncByUpdateTemplate = `		// Create or update the object.
+		op, err := ctrlkit.SyncOwnedObject(ctx, m.state.Client, m.state.target, desired)
+		if err != nil {
+			return ctrlkit.RequeueIfError(fmt.Errorf("unable to sync state '%s': %%w", err))
+		}
+		logger.Info("State synced.", "state", "%s", "operation", op)`
+
+	syncByApplyComments = `// It applies the object with a controller reference to the target by server-side apply,
+// with the field manager %s. Objects owned by others are refused.`
+
+	syncByApplyNoForceComments = `
+// The ownership of the fields isn't forced, so conflicts with other field managers are
+// reported as *ctrlkit.ApplyConflictError.`
+
+	syncByApplyTemplate = `		// Refuse the object owned by others.
+		if _, err := m.state.Get%s(ctx); err != nil {
+			return ctrlkit.RequeueIfError(err)
+		}
+
+		// Apply the object.
+		if err := ctrlkit.ApplyOwnedObject(ctx, m.state.Client, m.state.target, desired, %s, %t); err != nil {
+			return ctrlkit.RequeueIfError(fmt.Errorf("unable to sync state '%s': %%w", err))
+		}
+		logger.Info("State applied.", "state", "%s")`
+
+	mgrFieldManagerTemplate = `// %s is the field manager of server-side apply of %s.
+const %s = "%s"
+`
+)
+
+func fieldManagerConst(mgr *ControllerManagerDeclaration) string {
+	return mgr.Name + "FieldManager"
+}
+
+func syncActionNameConst(mgr *ControllerManagerDeclaration, state *StateDeclaration) string {
+	return mgr.TargetType + "Action_" + state.SyncActionName()
+}
+
+// generateDesiredSelectors generates the codes setting the selectors of the state onto the
+// desired object, so that it could be got by the state later.
+func generateDesiredSelectors(state *StateDeclaration) (string, error) {
+	var lines []string
+	if namespaceExpr, namespaced, err := getStateNamespaceExpr(state, "m.state.target"); err != nil {
+		return "", err
+	} else if namespaced {
+		lines = append(lines, "desired.Namespace = "+namespaceExpr)
+	}
+
+	if nameSelector, ok := state.Selectors["name"]; ok {
+		nameExpr, err := getStrExpr(nameSelector, "m.state.target")
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, "desired.Name = "+nameExpr)
+	}
+
+	keys := lo.Filter(lo.Keys(state.Selectors), func(k string, _ int) bool {
+		return strings.HasPrefix(k, "labels/")
+	})
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		lines = append(lines, "if desired.Labels == nil {\n\tdesired.Labels = make(map[string]string)\n}")
+	}
+	for _, k := range keys {
+		value, err := getStrExpr(state.Selectors[k], "m.state.target")
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("desired.Labels[\"%s\"] = %s", k[7:], value))
+	}
+
+	return indentStr(strings.Join(lines, "\n"), "\t\t"), nil
+}
+
+func generateSyncMethods(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) (string, error) {
+	var methods []string
+
+	for _, state := range builderStates(mgr) {
+		builder, _ := state.Builder()
+		selectors, err := generateDesiredSelectors(&state)
+		if err != nil {
+			return "", err
+		}
+		nameConst := syncActionNameConst(mgr, &state)
+
+		comments := syncByUpdateComments
+		syncCodes := fmt.Sprintf(syncByUpdateTemplate, state.Name, state.Name)
+		if mgr.UsesApply(&state) {
+			comments = fmt.Sprintf(syncByApplyComments, fieldManagerConst(mgr))
+			if !mgr.ForcesApply(&state) {
+				comments += syncByApplyNoForceComments
+			}
+			syncCodes = fmt.Sprintf(syncByApplyTemplate,
+				upperTheFirstCharInWord(state.Name),
+				fieldManagerConst(mgr), mgr.ForcesApply(&state),
+				state.Name,
+				state.Name,
+			)
+		}
+
+		methods = append(methods, fmt.Sprintf(mgrSyncTemplate,
+			state.SyncActionName(), state.Name, builder,
+			comments,
+			mgr.Name, state.SyncActionName(),
+			nameConst,
+			nameConst,
+			nameConst,
+			nameConst,
+			builder,
+			selectors,
+			syncCodes,
+		))
+	}
+
+	if lo.SomeBy(builderStates(mgr), func(s StateDeclaration) bool { return mgr.UsesApply(&s) }) {
+		methods = append([]string{fmt.Sprintf(mgrFieldManagerTemplate,
+			fieldManagerConst(mgr), mgr.Name,
+			fieldManagerConst(mgr), mgr.GetFieldManager(),
+		)}, methods...)
+	}
+
+	return strings.Join(methods, "\n"), nil
+}
+
+const (
+	mgrPruneTemplate = `// %s generates the action pruning the objects of state "%s" owned by the target, which
+// are not in the desired names returned by %s. Objects are deleted in background by
+// default, and could be only reported in dry-run mode. See ctrlkit.PruneOption.
+func (m *%s) %s(opts ...ctrlkit.PruneOption) ctrlkit.Action {
+	return ctrlkit.NewAction(%s, func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = ctrlkit.StartActionRun(ctx)
+		logger := m.logger.WithValues("action", %s)
+
+		// Get states.
+		%s, err := m.state.Get%s(ctx)
+		if err != nil {
+			return m.failToGetStates(ctx, logger, %s, err)
+		}
+
+		// Invoke action.
+		if m.hook != nil {
+			defer func() { m.hook.PostRun(ctx, logger, %s, result, err) }()
+			m.hook.PreRun(ctx, logger, %s, map[string]runtime.Object{
+				"%s": &%sList{Items: %s},
+			})
+		}
+
+		// Get the desired names.
+		desired, err := m.impl.%s(ctx, logger, %s)
+		if err != nil {
+			return ctrlkit.RequeueIfError(err)
+		}
+
+		// Prune the objects not desired.
+		objs := make([]client.Object, 0, len(%s))
+		for i := range %s {
+			objs = append(objs, &%s[i])
+		}
+		pruned, err := ctrlkit.PruneOwnedObjects(ctx, m.state.Client, m.state.target, objs, desired, opts...)
+		if err != nil {
+			return ctrlkit.RequeueIfError(fmt.Errorf("unable to prune state '%s': %%w", err))
+		}
+		if len(pruned.Pruned) > 0 {
+			logger.Info("State pruned.", "state", "%s", "objects", pruned.Pruned, "dryRun", pruned.DryRun)
+		}
+
+		return ctrlkit.NoRequeue()
+	})
+}
+`
+)
+
+func pruneActionNameConst(mgr *ControllerManagerDeclaration, state *StateDeclaration) string {
+	return mgr.TargetType + "Action_" + state.PruneActionName()
+}
+
+func generatePruneMethods(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) (string, error) {
+	var methods []string
+
+	for _, state := range prunerStates(mgr) {
+		pruner, _ := state.Pruner()
+		paramType, err := getParamRefType(doc, mgr, state.Name)
+		if err != nil {
+			return "", err
+		}
+		nameConst := pruneActionNameConst(mgr, &state)
+		methods = append(methods, fmt.Sprintf(mgrPruneTemplate,
+			state.PruneActionName(), state.Name, pruner,
+			mgr.Name, state.PruneActionName(),
+			nameConst,
+			nameConst,
+			state.Name, upperTheFirstCharInWord(state.Name), nameConst,
+			nameConst,
+			nameConst,
+			state.Name, strings.TrimPrefix(paramType, "[]"), state.Name,
+			pruner, state.Name,
+			state.Name,
+			state.Name,
+			state.Name,
+			state.Name,
+			state.Name,
+		))
+	}
+
+	return strings.Join(methods, "\n"), nil
+}
+
+const (
+	mgrSetupTemplate = `// Setup%sWithManager sets up the controller of %s with the manager, reconciled by r.
+// It watches %s, the owned states, and the states selected by the labels of the target name.
+// The field indexes used by the states are registered as well.%s
+func Setup%sWithManager(mgr ctrl.Manager, r reconcile.Reconciler) error {
+	if err := Register%sIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&%s{}).%s
+		Complete(r)
+}
+`
+)
+
+const (
+	mgrIndexesTemplate = `// %sFieldIndexes returns the field indexes used by the states of %s.
+func %sFieldIndexes() []ctrlkit.FieldIndex {
+	return %s
+}
+
+// Register%sIndexes registers the field indexes used by the states of %s to the
+// indexer, e.g., mgr.GetFieldIndexer().
+func Register%sIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	return ctrlkit.RegisterFieldIndexes(ctx, indexer, %sFieldIndexes()...)
+}
+
+// With%sIndexes wraps the client, e.g., a fake one in tests, to serve the field
+// selectors of the states of %s with the same indexes.
+func With%sIndexes(c client.Client) client.Client {
+	return ctrlkit.WithFieldIndexes(c, %sFieldIndexes()...)
+}
+`
+)
+
+// resolveGoType returns the GVK and the Go type of the alias.
+func resolveGoType(doc *ControllerManagerDocument, alias string) (schema.GroupVersionKind, string, error) {
+	gvk, err := parseGvk(doc.GetGvkByAlias(alias))
+	if err != nil {
+		return schema.GroupVersionKind{}, "", err
+	}
+	typeBind := doc.GvPkgBinds[gvk.GroupVersion().String()]
+	return gvk, constructPkgAliasForGvPkg(typeBind) + "." + gvk.Kind, nil
+}
+
+// ResolveGoType returns the Go type of the alias, e.g., "batchv1.Job", and the package of it.
+func ResolveGoType(doc *ControllerManagerDocument, alias string) (goType string, pkg string, err error) {
+	gvk, goType, err := resolveGoType(doc, alias)
+	if err != nil {
+		return "", "", err
+	}
+	return goType, doc.GetGvPkg(gvk.GroupVersion().String()), nil
+}
+
+// labelOfTargetField returns the label of the state whose value is exactly the field of the
+// target, e.g., "${target.Name}", which could be mapped back to the target.
+func labelOfTargetField(state *StateDeclaration, field string) (string, bool) {
+	keys := lo.Keys(state.Selectors)
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.HasPrefix(k, "labels/") && state.Selectors[k] == field {
+			return k[7:], true
+		}
+	}
+	return "", false
+}
+
+// generateEnqueueByLabels generates the event handler mapping the objects of the state back
+// to the target by the labels, or an empty string if they couldn't be mapped back.
+func generateEnqueueByLabels(mgr *ControllerManagerDeclaration, state *StateDeclaration) string {
+	label, ok := labelOfTargetField(state, "${target.Name}")
+	if !ok {
+		return ""
+	}
+	if mgr.ClusterScoped {
+		return fmt.Sprintf("ctrlkit.EnqueueRequestsByLabels(\"\", \"%s\")", label)
+	}
+	if namespace, namespaced := state.Namespace(); namespaced && namespace == "${target.Namespace}" {
+		return fmt.Sprintf("ctrlkit.EnqueueRequestsByLabel(\"%s\")", label)
+	}
+	// Objects in other namespaces must carry the namespace of the target as well.
+	if namespaceLabel, ok := labelOfTargetField(state, "${target.Namespace}"); ok {
+		return fmt.Sprintf("ctrlkit.EnqueueRequestsByLabels(\"%s\", \"%s\")", namespaceLabel, label)
+	}
+	return ""
+}
+
+// generateFieldIndexer returns the codes of the indexer of the field. The controller is
+// indexed by the name of the target, and the others by the path of the field.
+func generateFieldIndexer(targetGvk schema.GroupVersionKind, field string) string {
+	switch field {
+	case ".metadata.controller":
+		return fmt.Sprintf("ctrlkit.IndexByController(\"%s\", \"%s\")", targetGvk.GroupVersion().String(), targetGvk.Kind)
+	default:
+		return fmt.Sprintf("ctrlkit.IndexByFieldPath(\"%s\")", field)
+	}
+}
+
+func generateIndexesCodes(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) (string, error) {
+	targetGvk, _, err := resolveGoType(doc, mgr.TargetType)
+	if err != nil {
+		return "", err
+	}
+
+	var indexes []string
+	indexed := make(map[string]bool)
+
+	stateNames := lo.Keys(mgr.States)
+	sort.Strings(stateNames)
+	for _, stateName := range stateNames {
+		state := mgr.States[stateName]
+		_, stateGoType, err := resolveGoType(doc, state.Type)
+		if err != nil {
+			return "", err
+		}
+
+		fields := lo.Filter(lo.Keys(state.Selectors), func(k string, _ int) bool {
+			return strings.HasPrefix(k, "fields/")
+		})
+		sort.Strings(fields)
+		for _, k := range fields {
+			field := k[7:]
+			if indexed[stateGoType+field] {
+				continue
+			}
+			indexes = append(indexes, fmt.Sprintf("{Object: &%s{}, Field: \"%s\", Extractor: %s},",
+				stateGoType, field, generateFieldIndexer(targetGvk, field)))
+			indexed[stateGoType+field] = true
+		}
+	}
+
+	indexesExpr := "nil"
+	if len(indexes) > 0 {
+		indexesExpr = "[]ctrlkit.FieldIndex{\n\t\t" + strings.Join(indexes, "\n\t\t") + "\n\t}"
+	}
+
+	return fmt.Sprintf(mgrIndexesTemplate,
+		mgr.Name, mgr.Name,
+		mgr.Name,
+		indexesExpr,
+		mgr.Name, mgr.Name,
+		mgr.Name,
+		mgr.Name,
+		mgr.Name, mgr.Name,
+		mgr.Name,
+		mgr.Name,
+	), nil
+}
+
+func generateSetupCodes(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) (string, error) {
+	_, targetGoType, err := resolveGoType(doc, mgr.TargetType)
+	if err != nil {
+		return "", err
+	}
+
+	var watches, unwatched []string
+	watched := make(map[string]bool)
+
+	stateNames := lo.Keys(mgr.States)
+	sort.Strings(stateNames)
+	for _, stateName := range stateNames {
+		state := mgr.States[stateName]
+		_, stateGoType, err := resolveGoType(doc, state.Type)
+		if err != nil {
+			return "", err
+		}
+
+		if _, owned := state.Selectors["owned"]; owned {
+			if !watched["owns:"+stateGoType] {
+				watches = append(watches, fmt.Sprintf("Owns(&%s{})", stateGoType))
+				watched["owns:"+stateGoType] = true
+			}
+		} else if handler := generateEnqueueByLabels(mgr, &state); handler != "" {
+			if !watched["watches:"+stateGoType+handler] {
+				watches = append(watches, fmt.Sprintf("Watches(&source.Kind{Type: &%s{}}, %s)", stateGoType, handler))
+				watched["watches:"+stateGoType+handler] = true
+			}
+		} else {
+			unwatched = append(unwatched, stateName)
+		}
+	}
+
+	// The changes of the states not watched don't trigger the reconciliation, which is
+	// noted in the setup function.
+	var unwatchedComments string
+	if len(unwatched) > 0 {
+		unwatchedComments = fmt.Sprintf("\n//\n// The states not owned are watched only if they could be mapped back to %s by the labels,\n"+
+			"// so the changes of these states don't trigger the reconciliation: %s.", mgr.TargetType, strings.Join(unwatched, ", "))
+	}
+
+	return fmt.Sprintf(mgrSetupTemplate,
+		mgr.Name, mgr.TargetType,
+		mgr.TargetType,
+		unwatchedComments,
+		mgr.Name,
+		mgr.Name,
+		targetGoType, strings.Join(lo.Map(watches, func(s string, _ int) string {
+			return "\n\t\t" + s + "."
+		}), ""),
+	), nil
+}
+
+const (
+	mgrWorkflowTemplate = `// Workflow assembles the actions of %s into the reconcile workflow declared.%s
+func (m *%s) Workflow() ctrlkit.ReconcileAction {
+	return %s
+}
+`
+
+	mgrWorkflowPredicateTemplate = `ctrlkit.Named("%s", func(ctx context.Context) (bool, error) {
+%s	return m.impl.%s(ctx, m.logger.WithValues("predicate", "%s"))
+%s})`
+)
+
+// formatDurationInGo formats the duration into a Go expression, e.g., "30 * time.Second".
+func formatDurationInGo(d time.Duration) string {
+	for _, unit := range []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+	} {
+		if d%unit.d == 0 {
+			return fmt.Sprintf("%d * %s", d/unit.d, unit.name)
+		}
+	}
+	return fmt.Sprintf("time.Duration(%d)", d)
+}
+
+// generateCall generates the call of the function with the arguments, one per line.
+func generateCall(f string, args []string, indent string) string {
+	return f + "(\n" + strings.Join(lo.Map(args, func(arg string, _ int) string {
+		return indent + "\t" + arg + ",\n"
+	}), "") + indent + ")"
+}
+
+// generateWorkflowSteps generates the action running the steps sequentially.
+func generateWorkflowSteps(steps []WorkflowStep, indent string) string {
+	if len(steps) == 1 {
+		return generateWorkflowStep(&steps[0], indent)
+	}
+	return generateCall("ctrlkit.Sequential", generateWorkflowStepList(steps, indent+"\t"), indent)
+}
+
+func generateWorkflowStepList(steps []WorkflowStep, indent string) []string {
+	return lo.Map(steps, func(step WorkflowStep, _ int) string {
+		return generateWorkflowStep(&step, indent)
+	})
+}
+
+func generateWorkflowStep(step *WorkflowStep, indent string) string {
+	switch step.Kind {
+	case WorkflowAction:
+		return "m." + step.Action + "()"
+	case WorkflowSequential:
+		return generateCall("ctrlkit.Sequential", generateWorkflowStepList(step.Steps, indent+"\t"), indent)
+	case WorkflowJoin:
+		return generateCall("ctrlkit.Join", generateWorkflowStepList(step.Steps, indent+"\t"), indent)
+	case WorkflowParallelJoin:
+		if step.Arg == "" {
+			return generateCall("ctrlkit.JoinInParallel", generateWorkflowStepList(step.Steps, indent+"\t"), indent)
+		}
+		return generateCall("ctrlkit.JoinInParallelWithLimit",
+			append([]string{step.Arg}, generateWorkflowStepList(step.Steps, indent+"\t")...), indent)
+	case WorkflowWhen, WorkflowUnless:
+		predicate := fmt.Sprintf(mgrWorkflowPredicateTemplate, step.Arg, indent+"\t", step.Arg, step.Arg, indent+"\t")
+		return generateCall("ctrlkit."+upperTheFirstCharInWord(step.Kind), []string{
+			predicate,
+			generateWorkflowSteps(step.Steps, indent+"\t"),
+		}, indent)
+	case WorkflowTimeout:
+		d, _ := time.ParseDuration(step.Arg)
+		return generateCall("ctrlkit.Timeout", []string{
+			formatDurationInGo(d),
+			generateWorkflowSteps(step.Steps, indent+"\t"),
+		}, indent)
+	default:
+		panic("unknown workflow step: " + step.Kind)
+	}
+}
+
+func generateWorkflowCodes(mgr *ControllerManagerDeclaration) string {
+	if mgr.Workflow == nil {
+		return ""
+	}
+
+	var workflow string
+	if len(mgr.Workflow.Deferred) == 0 {
+		workflow = generateWorkflowSteps(mgr.Workflow.Steps, "\t")
+	} else {
+		deferred := lo.Map(mgr.Workflow.Deferred, func(name string, _ int) string {
+			return "m." + name + "()"
+		})
+		deferredAction := deferred[0]
+		if len(deferred) > 1 {
+			deferredAction = generateCall("ctrlkit.Join", deferred, "\t\t")
+		}
+		workflow = generateCall("ctrlkit.Defer", []string{
+			generateWorkflowSteps(mgr.Workflow.Steps, "\t\t"),
+			deferredAction,
+		}, "\t")
+	}
+
+	comments := strings.Join(lo.Map(mgr.Workflow.Comments, func(s string, _ int) string {
+		return "\n// " + s
+	}), "")
+
+	return fmt.Sprintf(mgrWorkflowTemplate,
+		mgr.Name, comments,
+		mgr.Name,
+		workflow,
+	)
+}
+
+const (
+	mgrFinalizerTemplate = `// %s is the finalizer of %s.
+const %s = "%s"
+
+// Finalizer generates the action managing the finalizer "%s".
+// When the target isn't being deleted, it adds the finalizer if missing. Otherwise, it runs
+// the clean up actions sequentially, removes the finalizer after they succeed, and exits.
+func (m *%s) Finalizer() ctrlkit.Action {
+	return ctrlkit.Finalizer(m.state.Client, m.state.target, %s, %s)
+}
+`
+)
+
+func finalizerNameConst(mgr *ControllerManagerDeclaration) string {
+	return mgr.TargetType + "Finalizer"
+}
+
+func generateFinalizerCodes(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) string {
+	if mgr.Finalizer == nil {
+		return ""
+	}
+
+	cleanup := "ctrlkit.Nop"
+	if len(mgr.Finalizer.Actions) > 0 {
+		cleanup = "ctrlkit.Sequential(\n" + strings.Join(lo.Map(mgr.Finalizer.Actions, func(s string, _ int) string {
+			return "\t\tm." + s + "(),\n"
+		}), "") + "\t)"
+	}
+
+	return fmt.Sprintf(mgrFinalizerTemplate,
+		finalizerNameConst(mgr), mgr.Name,
+		finalizerNameConst(mgr), mgr.Finalizer.Name,
+		mgr.Finalizer.Name,
+		mgr.Name,
+		finalizerNameConst(mgr), cleanup,
+	)
+}
+
 func actionsNameConsts(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) []string {
-	return lo.Map(mgr.Actions, func(act ActionDeclaration, _ int) string {
+	consts := lo.Map(mgr.Actions, func(act ActionDeclaration, _ int) string {
 		return fmt.Sprintf("%s=\"%s\"", actionNameConst(mgr, &act), act.Name)
 	})
+	for _, state := range builderStates(mgr) {
+		consts = append(consts, fmt.Sprintf("%s=\"%s\"", syncActionNameConst(mgr, &state), state.SyncActionName()))
+	}
+	for _, state := range prunerStates(mgr) {
+		consts = append(consts, fmt.Sprintf("%s=\"%s\"", pruneActionNameConst(mgr, &state), state.PruneActionName()))
+	}
+	return consts
 }
 
 func formatIntoManagerGoCode(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) (string, error) {
@@ -751,6 +1553,36 @@ func formatIntoManagerGoCode(doc *ControllerManagerDocument, mgr *ControllerMana
 	if mgrMethods, err = generateMgrMethods(doc, mgr); err != nil {
 		return "", err
 	}
+	syncMethods, err := generateSyncMethods(doc, mgr)
+	if err != nil {
+		return "", err
+	}
+	if syncMethods != "" {
+		mgrMethods += "\n" + syncMethods
+	}
+	pruneMethods, err := generatePruneMethods(doc, mgr)
+	if err != nil {
+		return "", err
+	}
+	if pruneMethods != "" {
+		mgrMethods += "\n" + pruneMethods
+	}
+	if finalizerCodes := generateFinalizerCodes(doc, mgr); finalizerCodes != "" {
+		mgrMethods += "\n" + finalizerCodes
+	}
+	if workflowCodes := generateWorkflowCodes(mgr); workflowCodes != "" {
+		mgrMethods += "\n" + workflowCodes
+	}
+	indexesCodes, err := generateIndexesCodes(doc, mgr)
+	if err != nil {
+		return "", err
+	}
+	mgrMethods += "\n" + indexesCodes
+	setupCodes, err := generateSetupCodes(doc, mgr)
+	if err != nil {
+		return "", err
+	}
+	mgrMethods += "\n" + setupCodes
 
 	return fmt.Sprintf(managerStubCodeTemplate,
 		mgr.Name, mgr.Name,
@@ -765,6 +1597,7 @@ func formatIntoManagerGoCode(doc *ControllerManagerDocument, mgr *ControllerMana
 		mgr.Name,
 		mgr.Name,
 		mgr.Name,
+		mgr.Name,
 		mgrMethods,
 		mgr.Name, mgr.Name,
 		mgr.Name, mgr.Name,
@@ -802,6 +1635,98 @@ func generateBody(doc *ControllerManagerDocument) (string, error) {
 	return bodyBuf.String(), nil
 }
 
+const (
+	fakeImplTemplate = `// Fake%sImpl is a fake %sImpl for tests.
+// Every method records the call with the states, and then calls the func field if set, or
+// returns the canned results otherwise.
+type Fake%sImpl struct {
+	mu sync.Mutex
+%s}
+
+var _ %sImpl = &Fake%sImpl{}
+%s`
+
+	fakeImplFieldsTemplate = `
+	// %sFunc is called by %s if set.
+	%sFunc func%s
+	// %sResult and %sErr are returned by %s if %sFunc isn't set.
+	%sResult %s
+	%sErr error
+	// %sCalls are the recorded calls of %s.
+	%sCalls []%s
+`
+
+	fakeImplMethodTemplate = `
+// %s is a recorded call of %s.
+type %s struct {
+%s}
+
+// %s records the call, and calls %sFunc if set, or returns the canned results otherwise.
+func (f *Fake%sImpl) %s%s {
+	f.mu.Lock()
+	f.%sCalls = append(f.%sCalls, %s{%s})
+	fn, result, err := f.%sFunc, f.%sResult, f.%sErr
+	f.mu.Unlock()
+
+	if fn != nil {
+		return fn(%s)
+	}
+	return result, err
+}
+`
+)
+
+func fakeImplCallType(mgr *ControllerManagerDeclaration, m *implMethod) string {
+	return "Fake" + mgr.Name + "Impl_" + m.name + "Call"
+}
+
+func generateFakeImplCodes(doc *ControllerManagerDocument, mgr *ControllerManagerDeclaration) (string, error) {
+	implMethods, err := implMethods(doc, mgr)
+	if err != nil {
+		return "", err
+	}
+
+	fields, methods := &strings.Builder{}, &strings.Builder{}
+	for _, m := range implMethods {
+		callType := fakeImplCallType(mgr, &m)
+		fields.WriteString(fmt.Sprintf(fakeImplFieldsTemplate,
+			m.name, m.name,
+			m.name, m.signature(),
+			m.name, m.name, m.name, m.name,
+			m.name, m.result,
+			m.name,
+			m.name, m.name,
+			m.name, callType,
+		))
+
+		callFields, callValues := "", make([]string, 0, len(m.params))
+		args := []string{"ctx", "logger"}
+		for _, param := range m.params {
+			callFields += fmt.Sprintf("\t%s %s\n", upperTheFirstCharInWord(param.name), param.typ)
+			callValues = append(callValues, upperTheFirstCharInWord(param.name)+": "+param.name)
+			args = append(args, param.name)
+		}
+		methods.WriteString(fmt.Sprintf(fakeImplMethodTemplate,
+			callType, m.name,
+			callType,
+			callFields,
+			m.name, m.name,
+			mgr.Name, m.name, m.signature(),
+			m.name, m.name, callType, strings.Join(callValues, ", "),
+			m.name, m.name, m.name,
+			strings.Join(args, ", "),
+		))
+	}
+
+	return fmt.Sprintf(fakeImplTemplate,
+		mgr.Name, mgr.Name,
+		mgr.Name,
+		fields.String(),
+		mgr.Name, mgr.Name,
+		methods.String(),
+	), nil
+}
+
 func GenerateStubCodes(doc *ControllerManagerDocument, pkgName string) (string, error) {
 	imports, err := generateImports(doc)
 	if err != nil {
@@ -816,6 +1741,31 @@ func GenerateStubCodes(doc *ControllerManagerDocument, pkgName string) (string,
 	return formatIntoGoFile(pkgName, imports, body)
 }
 
+// GenerateFakeImplCodes generates the fake impls of the managers for tests, which are
+// supposed to be in the same package of the stub codes.
+func GenerateFakeImplCodes(doc *ControllerManagerDocument, pkgName string) (string, error) {
+	imports, err := generateImports(doc)
+	if err != nil {
+		return "", err
+	}
+	imports = append(imports, "\"sync\"")
+
+	bodyBuf := &bytes.Buffer{}
+	mgrNames := lo.Keys(doc.Decls)
+	sort.Strings(mgrNames)
+	for _, mgrName := range mgrNames {
+		mgr := doc.Decls[mgrName]
+		fakeImplCodes, err := generateFakeImplCodes(doc, &mgr)
+		if err != nil {
+			return "", err
+		}
+		bodyBuf.WriteString(fakeImplCodes)
+		bodyBuf.WriteRune('\n')
+	}
+
+	return formatIntoGoFile(pkgName, imports, bodyBuf.String())
+}
+
 func GenerateStubCodesIntoFile(doc *ControllerManagerDocument, path string) error {
 	fileName := doc.FileName[:strings.LastIndex(doc.FileName, ".")] + ".go"
 	s, err := GenerateStubCodes(doc, filepath.Base(path))
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -33,3 +34,459 @@ func Test_GenerateStubCodes(t *testing.T) {
 func Test_GetStrExpr(t *testing.T) {
 	fmt.Println(getStrExpr("risingwave-${target.Name}", "s.target"))
 }
+
+func generateTestDoc(t *testing.T, body string) string {
+	s, err := GenerateStubCodes(parseTestDoc(t, body), "tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func Test_GenerateStubCodes_Finalizer(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    finalizer example.io/cleanup {
+        Forget()
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"\tclient.Client\n",
+		`const JobFinalizer = "example.io/cleanup"`,
+		"func (m *JobManager) Finalizer() ctrlkit.Action {",
+		"ctrlkit.Finalizer(m.state.Client, m.state.target, JobFinalizer, ctrlkit.Sequential(\n\t\tm.Forget(),\n\t))",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+
+	s = generateTestDoc(t, `
+decl JobManager for Job {
+    action {
+        Forget()
+    }
+}
+`)
+	if !strings.Contains(s, "\tclient.Reader\n") || strings.Contains(s, "Finalizer") {
+		t.Fatal("codes of finalizer should not be generated")
+	}
+}
+
+func Test_GenerateStubCodes_Sync(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pod Pod {
+            name=${target.Name}-pod
+            owned
+            build
+        }
+        sidecar Pod {
+            labels/sidecar=${target.Name}
+            owned
+            build=NewSidecar
+        }
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"\tclient.Client\n",
+		`JobAction_SyncPod="SyncPod"`,
+		"BuildPod(ctx context.Context, logger logr.Logger) (*corev1.Pod, error)",
+		"NewSidecar(ctx context.Context, logger logr.Logger) (*corev1.Pod, error)",
+		"func (m *JobManager) SyncPod() ctrlkit.Action {",
+		"desired.Name = m.state.target.Name + \"-pod\"",
+		"desired.Labels[\"sidecar\"] = m.state.target.Name",
+		"ctrlkit.SyncOwnedObject(ctx, m.state.Client, m.state.target, desired)",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+}
+
+func Test_GenerateStubCodes_SyncByApply(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    apply example-controller
+
+    state {
+        pod Pod {
+            name=${target.Name}-pod
+            owned
+            build
+        }
+    }
+}
+`)
+
+	for _, expected := range []string{
+		`const JobManagerFieldManager = "example-controller"`,
+		"if _, err := m.state.GetPod(ctx); err != nil {",
+		"ctrlkit.ApplyOwnedObject(ctx, m.state.Client, m.state.target, desired, JobManagerFieldManager, true)",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+	if strings.Contains(s, "ctrlkit.SyncOwnedObject") {
+		t.Fatal("state should be synced by apply")
+	}
+}
+
+func Test_GenerateStubCodes_SyncByApplyNoForce(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    apply noforce
+
+    state {
+        pod Pod {
+            name=${target.Name}-pod
+            owned
+            build
+        }
+        sidecar Pod {
+            name=${target.Name}-sidecar
+            owned
+            build
+            apply=force
+        }
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"ctrlkit.ApplyOwnedObject(ctx, m.state.Client, m.state.target, desired, JobManagerFieldManager, false)",
+		"ctrlkit.ApplyOwnedObject(ctx, m.state.Client, m.state.target, desired, JobManagerFieldManager, true)",
+		"// reported as *ctrlkit.ApplyConflictError.\nfunc (m *JobManager) SyncPod() ctrlkit.Action {",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+}
+
+func Test_GenerateStubCodes_Prune(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+            owned
+            prune
+        }
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"\tclient.Client\n",
+		`JobAction_PrunePods="PrunePods"`,
+		"DesiredPods(ctx context.Context, logger logr.Logger, pods []corev1.Pod) ([]string, error)",
+		"func (m *JobManager) PrunePods(opts ...ctrlkit.PruneOption) ctrlkit.Action {",
+		"return m.failToGetStates(ctx, logger, JobAction_PrunePods, err)",
+		"desired, err := m.impl.DesiredPods(ctx, logger, pods)",
+		"ctrlkit.PruneOwnedObjects(ctx, m.state.Client, m.state.target, objs, desired, opts...)",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+}
+
+func Test_GenerateStubCodes_StateErrorsHooked(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+        }
+    }
+
+    action {
+        CheckPods(pods)
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"func (m *JobManager) failToGetStates(ctx context.Context, logger logr.Logger, action string, err error) (ctrl.Result, error) {",
+		"return m.failToGetStates(ctx, logger, JobAction_CheckPods, err)",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+	if strings.Index(s, "failToGetStates(ctx, logger, JobAction_CheckPods") > strings.Index(s, "m.hook.PreRun(ctx, logger, JobAction_CheckPods") {
+		t.Fatal("state errors should be reported before the hook of the action")
+	}
+}
+
+func Test_GenerateStubCodes_Setup(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+            fields/.metadata.controller=${target.Name}
+            owned
+        }
+        sidecars []Pod {
+            labels/sidecar=${target.Name}
+            owned
+        }
+        others []Pod {
+            labels/related-job=${target.Name}
+        }
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"func SetupJobManagerWithManager(mgr ctrl.Manager, r reconcile.Reconciler) error {",
+		"if err := RegisterJobManagerIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {",
+		"For(&batchv1.Job{}).\n\t\tWatches(&source.Kind{Type: &corev1.Pod{}}, ctrlkit.EnqueueRequestsByLabel(\"related-job\")).\n\t\tOwns(&corev1.Pod{}).\n\t\tComplete(r)",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+}
+
+func Test_GenerateStubCodes_SetupUnwatched(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+            owned
+        }
+        tiers []Pod {
+            labels/tier=web
+        }
+        prefixed []Pod {
+            labels/job=job-${target.Name}
+        }
+    }
+}
+`)
+
+	expected := "// The states not owned are watched only if they could be mapped back to Job by the labels,\n" +
+		"// so the changes of these states don't trigger the reconciliation: prefixed, tiers.\n" +
+		"func SetupJobManagerWithManager("
+	if !strings.Contains(s, expected) {
+		t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+	}
+	if strings.Contains(s, "Watches(") {
+		t.Fatalf("generated codes should not watch the states:\n%s", s)
+	}
+}
+
+func Test_GenerateStubCodes_Indexes(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+            fields/.metadata.controller=${target.Name}
+            fields/.spec.nodeName=node
+        }
+        runningPods []Pod {
+            fields/.metadata.controller=${target.Name}
+            fields/.status.phase=Running
+        }
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"client.MatchingLabels(matchingLabels),\n\t\tclient.MatchingFields{\n\t\t\t\".metadata.controller\":s.target.Name,\n\t\t\t\".spec.nodeName\":\"node\",\n\t\t})",
+		"func JobManagerFieldIndexes() []ctrlkit.FieldIndex {",
+		`{Object: &corev1.Pod{}, Field: ".metadata.controller", Extractor: ctrlkit.IndexByController("batch/v1", "Job")},`,
+		`{Object: &corev1.Pod{}, Field: ".spec.nodeName", Extractor: ctrlkit.IndexByFieldPath(".spec.nodeName")},`,
+		`{Object: &corev1.Pod{}, Field: ".status.phase", Extractor: ctrlkit.IndexByFieldPath(".status.phase")},`,
+		"func RegisterJobManagerIndexes(ctx context.Context, indexer client.FieldIndexer) error {",
+		"func WithJobManagerIndexes(c client.Client) client.Client {",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+
+	if strings.Count(s, `Field: ".metadata.controller"`) != 1 {
+		t.Fatal("field indexes should be deduplicated")
+	}
+}
+
+func Test_GenerateStubCodes_Scope(t *testing.T) {
+	s := generateTestDoc(t, `
+decl NodeManager for Node {
+    cluster-scoped
+
+    state {
+        pods []Pod {
+            labels/node=${target.Name}
+            all-namespaces
+        }
+        agent Pod {
+            name=agent-${target.Name}
+            namespace=kube-system
+            owned
+            build
+        }
+    }
+}
+
+decl JobManager for Job {
+    state {
+        nodes []Node {
+            labels/job=${target.Name}
+            cluster-scoped
+        }
+        configs []Pod {
+            labels/job=${target.Name}
+            labels/job-namespace=${target.Namespace}
+            namespace=shared
+        }
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"err := s.List(ctx, &podsList, \n\t\tclient.MatchingLabels(matchingLabels))",
+		"err := s.Get(ctx, types.NamespacedName{\n\t\tNamespace: \"kube-system\",\n\t\tName: \"agent-\" + s.target.Name,",
+		"desired.Namespace = \"kube-system\"",
+		`Watches(&source.Kind{Type: &corev1.Pod{}}, ctrlkit.EnqueueRequestsByLabels("", "node"))`,
+		"err := s.List(ctx, &nodesList, \n\t\tclient.MatchingLabels(matchingLabels))",
+		"err := s.List(ctx, &configsList, client.InNamespace(\"shared\"), ",
+		`Watches(&source.Kind{Type: &corev1.Pod{}}, ctrlkit.EnqueueRequestsByLabels("job-namespace", "job"))`,
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+	// Cluster-scoped objects without the namespace of the target can't be mapped back.
+	if strings.Contains(s, "Watches(&source.Kind{Type: &corev1.Node{}}") {
+		t.Fatalf("generated codes should not watch the nodes:\n%s", s)
+	}
+	if !strings.Contains(s, "// so the changes of these states don't trigger the reconciliation: nodes.\nfunc SetupJobManagerWithManager(") {
+		t.Fatalf("the states not watched should be noted:\n%s", s)
+	}
+}
+
+func Test_GenerateStubCodes_LabelRequirements(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+            labels/!canary
+        }
+        others []Pod {
+            labels/job=${target.Name}
+        }
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"//   + labels/!canary",
+		"client.MatchingLabelsSelector{\n\t\t\tSelector: ctrlkit.LabelSelector(matchingLabels, ctrlkit.MustParseLabelRequirements(\"!canary\")...),\n\t\t})",
+		"err := s.List(ctx, &othersList, client.InNamespace(s.target.Namespace), \n\t\tclient.MatchingLabels(matchingLabels))",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+}
+
+func Test_GenerateStubCodes_Workflow(t *testing.T) {
+	s := generateTestDoc(t, `
+decl JobManager for Job {
+    workflow {
+        Run()
+        unless Suspended {
+            timeout 1m30s {
+                Run()
+            }
+        }
+        parallel-join 2 {
+            Run()
+            Run()
+        }
+        defer UpdateStatus()
+        defer Forget()
+    }
+
+    action {
+        Run()
+        UpdateStatus()
+        Forget()
+    }
+}
+`)
+
+	for _, expected := range []string{
+		"\tSuspended(ctx context.Context, logger logr.Logger) (bool, error)",
+		"func (m *JobManager) Workflow() ctrlkit.ReconcileAction {",
+		"return ctrlkit.Defer(\n\t\tctrlkit.Sequential(\n\t\t\tm.Run(),\n\t\t\tctrlkit.Unless(\n\t\t\t\tctrlkit.Named(\"Suspended\", func(ctx context.Context) (bool, error) {",
+		"return m.impl.Suspended(ctx, m.logger.WithValues(\"predicate\", \"Suspended\"))",
+		"ctrlkit.Timeout(\n\t\t\t\t\t90 * time.Second,\n\t\t\t\t\tm.Run(),\n\t\t\t\t),",
+		"ctrlkit.JoinInParallelWithLimit(\n\t\t\t\t2,\n\t\t\t\tm.Run(),\n\t\t\t\tm.Run(),\n\t\t\t),",
+		"ctrlkit.Join(\n\t\t\tm.UpdateStatus(),\n\t\t\tm.Forget(),\n\t\t),\n\t)",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+}
+
+func Test_GenerateFakeImplCodes(t *testing.T) {
+	s, err := GenerateFakeImplCodes(parseTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+            owned
+            prune
+        }
+    }
+
+    workflow {
+        when Ready {
+            Run()
+        }
+    }
+
+    action {
+        Run(pods)
+    }
+}
+`), "tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"\"sync\"",
+		"type FakeJobManagerImpl struct {\n\tmu sync.Mutex\n",
+		"\tRunFunc func(ctx context.Context, logger logr.Logger, pods []corev1.Pod) (ctrl.Result, error)\n",
+		"\tRunResult ctrl.Result\n\tRunErr error\n",
+		"\tRunCalls []FakeJobManagerImpl_RunCall\n",
+		"type FakeJobManagerImpl_RunCall struct {\n\tPods []corev1.Pod\n}",
+		"f.RunCalls = append(f.RunCalls, FakeJobManagerImpl_RunCall{Pods: pods})",
+		"\tDesiredPodsResult []string\n",
+		"func (f *FakeJobManagerImpl) DesiredPods(ctx context.Context, logger logr.Logger, pods []corev1.Pod) ([]string, error) {",
+		"\tReadyResult bool\n",
+		"type FakeJobManagerImpl_ReadyCall struct {\n}",
+		"var _ JobManagerImpl = &FakeJobManagerImpl{}",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("generated codes should contain %q:\n%s", expected, s)
+		}
+	}
+}
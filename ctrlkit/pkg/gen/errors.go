@@ -0,0 +1,106 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorCode is the stable code of a parse error, which tools could match against.
+type ErrorCode string
+
+const (
+	CodeUnknownStatement        ErrorCode = "unknown-statement"
+	CodeUnclosedBlock           ErrorCode = "unclosed-block"
+	CodeRedeclaration           ErrorCode = "redeclaration"
+	CodeBindNotFound            ErrorCode = "bind-not-found"
+	CodeTypeNotFound            ErrorCode = "type-not-found"
+	CodeStateNotFound           ErrorCode = "state-not-found"
+	CodeInvalidBind             ErrorCode = "invalid-bind"
+	CodeInvalidAlias            ErrorCode = "invalid-alias"
+	CodeInvalidDecl             ErrorCode = "invalid-decl"
+	CodeInvalidState            ErrorCode = "invalid-state"
+	CodeInvalidSelector         ErrorCode = "invalid-selector"
+	CodeInvalidLabelRequirement ErrorCode = "invalid-label-requirement"
+	CodeInvalidAction           ErrorCode = "invalid-action"
+	CodeInvalidFinalizer        ErrorCode = "invalid-finalizer"
+	CodeInvalidBuilder          ErrorCode = "invalid-builder"
+	CodeInvalidPruner           ErrorCode = "invalid-pruner"
+	CodeInvalidApply            ErrorCode = "invalid-apply"
+	CodeInvalidScope            ErrorCode = "invalid-scope"
+	CodeInvalidWorkflow         ErrorCode = "invalid-workflow"
+	CodeActionNotFound          ErrorCode = "action-not-found"
+)
+
+var codeHints = map[ErrorCode]string{
+	CodeUnknownStatement:        `expect one of "bind", "alias" and "decl" at the top level`,
+	CodeUnclosedBlock:           `close the block with "}"`,
+	CodeRedeclaration:           "rename it or remove the duplicate",
+	CodeBindNotFound:            `bind the group version with "bind <group>/<version> <package>" first`,
+	CodeTypeNotFound:            `declare the type with "alias <Type> <group>/<version>/<Kind>" first`,
+	CodeStateNotFound:           "declare the state in the state block first",
+	CodeInvalidBind:             `expect "bind <group>/<version> <package>"`,
+	CodeInvalidAlias:            `expect "alias <Type> <group>/<version>/<Kind>"`,
+	CodeInvalidDecl:             `expect "decl <Name> for <Type> {", or one of "state", "action", "finalizer", "workflow", "apply" and "cluster-scoped" in it`,
+	CodeInvalidState:            `expect "<name> <Type> {" or "<name> []<Type> {"`,
+	CodeInvalidSelector:         `expect one selector per line, e.g., "labels/app=${target.Name}" or "owned"`,
+	CodeInvalidLabelRequirement: `expect "labels/<key> in (<values>)", "labels/<key> notin (<values>)", "labels/!<key>" or "labels/<key> exists" with literal values, on states without "name"`,
+	CodeInvalidAction:           `expect "<Action>(<state>, ...)"`,
+	CodeInvalidFinalizer:        `expect "finalizer <name> {"`,
+	CodeInvalidBuilder:          `"build" requires an "owned" state which isn't an array`,
+	CodeInvalidPruner:           `"prune" requires an "owned" array state`,
+	CodeInvalidApply:            `"apply" requires "build" and takes "force" or "noforce" on states, and at most a field manager and "noforce" on decls`,
+	CodeInvalidScope:            `select at most one of "namespace=<expr>", "cluster-scoped" and "all-namespaces", explicitly on states of "cluster-scoped" targets, and own only objects in the namespace of namespaced targets`,
+	CodeInvalidWorkflow:         `expect "<Action>()", "defer <Action>()" at the top, or a non-empty block of "sequential", "join", "parallel-join [limit]", "when <Predicate>", "unless <Predicate>" and "timeout <duration>"`,
+	CodeActionNotFound:          `declare the action in the action block, or use a generated one, e.g., "Sync<State>", "Prune<State>" and "Finalizer"`,
+}
+
+// ParseError is an error of parsing the document at a source position.
+type ParseError struct {
+	// File is the name of the document, if known.
+	File string `json:"file,omitempty"`
+	// Line is the line number, starting at 1.
+	Line int `json:"line"`
+	// Column is the byte offset of the token in the line, starting at 1.
+	Column int `json:"column"`
+	// Token is the offending token.
+	Token string `json:"token,omitempty"`
+	// Code is the stable code of the error.
+	Code ErrorCode `json:"code"`
+	// Msg describes what failed, e.g., "invalid state block".
+	Msg string `json:"msg"`
+	// Hint suggests how to fix the error.
+	Hint string `json:"hint,omitempty"`
+	// Err is the underlying error, if any.
+	Err error `json:"-"`
+}
+
+// Position returns the position of the error in the form of "file:line:column", or
+// "line:column" if the file is unknown.
+func (e *ParseError) Position() string {
+	if e.File == "" {
+		return fmt.Sprintf("%d:%d", e.Line, e.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Column)
+}
+
+func (e *ParseError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: parse error: %s", e.Position(), e.Msg)
+	}
+	return fmt.Sprintf("%s: parse error: %s: %s", e.Position(), e.Msg, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors are all the errors of parsing the document, in the order of positions.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
@@ -1,18 +1,41 @@
 package gen
 
 import (
+	"github.com/samber/lo"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// Pos is the position of a declaration in the document, where the line and the column
+// start at 1.
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Span is the span of a block in the document, from the line opening it to the line
+// closing it.
+type Span struct {
+	Pos Pos `json:"pos"`
+	End Pos `json:"end"`
+}
+
+// Comment is a comment line in the document.
+type Comment struct {
+	Pos  Pos    `json:"pos"`
+	Text string `json:"text"`
+}
+
 type GvBind struct {
 	Gv     string              `json:"gv"`
 	Parsed schema.GroupVersion `json:"-"`
 	Pkg    string              `json:"pkg"`
+	Pos    Pos                 `json:"-"`
 }
 
 type GvReflections struct {
 	GvPkgBinds map[string]GvBind `json:"binds"`
 	GvkAliases map[string]string `json:"aliases"`
+	AliasPos   map[string]Pos    `json:"-"`
 }
 
 func (r *GvReflections) AddGvBind(gv string, pkg string, parsed schema.GroupVersion) bool {
@@ -55,6 +78,11 @@ type ControllerManagerDocument struct {
 	FileName      string
 	GvReflections `json:",inline"`
 	Decls         map[string]ControllerManagerDeclaration `json:"decls"`
+
+	// Comments are all the comments in the document, in the order of lines. The comments of
+	// the declarations are kept in them as well, with the positions of which the printer puts
+	// the comments back.
+	Comments []Comment `json:"-"`
 }
 
 func (d *ControllerManagerDocument) DoesControllerManagerDeclarationExists(name string) bool {
@@ -68,6 +96,93 @@ type StateDeclaration struct {
 	Type      string            `json:"type"`
 	IsArray   bool              `json:"is_array"`
 	Selectors map[string]string `json:"selectors"`
+	Pos       Pos               `json:"-"`
+	End       Pos               `json:"-"`
+
+	// SelectorPos are the positions of the selectors.
+	SelectorPos map[string]Pos `json:"-"`
+	// LabelRequirementPos are the positions of the label requirements.
+	LabelRequirementPos []Pos `json:"-"`
+
+	// LabelRequirements are the set-based label requirements besides the equality ones in
+	// selectors, e.g., "tier in (a,b)", "!legacy" and "app", which is "app exists".
+	LabelRequirements []string `json:"label_requirements,omitempty"`
+}
+
+// Builder returns the name of the impl method building the desired object of the state,
+// and whether the state declares one with the "build" selector. The name defaults to
+// "Build<State>" when the selector has no value.
+func (d *StateDeclaration) Builder() (string, bool) {
+	builder, ok := d.Selectors["build"]
+	if !ok {
+		return "", false
+	}
+	if builder == "" {
+		return "Build" + upperTheFirstCharInWord(d.Name), true
+	}
+	return builder, true
+}
+
+// SyncActionName returns the name of the generated action syncing the state.
+func (d *StateDeclaration) SyncActionName() string {
+	return "Sync" + upperTheFirstCharInWord(d.Name)
+}
+
+// Pruner returns the name of the impl method returning the names of the desired objects of
+// the state, and whether the state declares one with the "prune" selector. The name
+// defaults to "Desired<State>" when the selector has no value.
+func (d *StateDeclaration) Pruner() (string, bool) {
+	pruner, ok := d.Selectors["prune"]
+	if !ok {
+		return "", false
+	}
+	if pruner == "" {
+		return "Desired" + upperTheFirstCharInWord(d.Name), true
+	}
+	return pruner, true
+}
+
+// PruneActionName returns the name of the generated action pruning the state.
+func (d *StateDeclaration) PruneActionName() string {
+	return "Prune" + upperTheFirstCharInWord(d.Name)
+}
+
+// GeneratedActionNames returns the names of the actions generated for the state.
+func (d *StateDeclaration) GeneratedActionNames() []string {
+	var names []string
+	if _, ok := d.Builder(); ok {
+		names = append(names, d.SyncActionName())
+	}
+	if _, ok := d.Pruner(); ok {
+		names = append(names, d.PruneActionName())
+	}
+	return names
+}
+
+// Namespace returns the namespace expression of the state, and whether the state is in a
+// single namespace. It's the "namespace" selector if declared, or otherwise the namespace
+// of the target. States selected with "cluster-scoped" or "all-namespaces" aren't in a
+// single namespace.
+func (d *StateDeclaration) Namespace() (string, bool) {
+	if d.IsClusterScoped() || d.IsInAllNamespaces() {
+		return "", false
+	}
+	if namespace, ok := d.Selectors["namespace"]; ok {
+		return namespace, true
+	}
+	return "${target.Namespace}", true
+}
+
+// IsClusterScoped tells if the state is of a cluster-scoped type, e.g., Node.
+func (d *StateDeclaration) IsClusterScoped() bool {
+	_, ok := d.Selectors["cluster-scoped"]
+	return ok
+}
+
+// IsInAllNamespaces tells if the state is selected from all namespaces.
+func (d *StateDeclaration) IsInAllNamespaces() bool {
+	_, ok := d.Selectors["all-namespaces"]
+	return ok
 }
 
 func (d *StateDeclaration) AddSelector(key, value string) bool {
@@ -82,6 +197,72 @@ type ActionDeclaration struct {
 	Comments []string `json:"comments"`
 	Name     string   `json:"name"`
 	Params   []string `json:"params"`
+	Pos      Pos      `json:"-"`
+}
+
+// FinalizerDeclaration declares a finalizer of the target and the actions to clean up
+// before it's removed.
+type FinalizerDeclaration struct {
+	Comments []string `json:"comments"`
+	Name     string   `json:"name"`
+	Actions  []string `json:"actions"`
+	Pos      Pos      `json:"-"`
+	End      Pos      `json:"-"`
+}
+
+// Kinds of the workflow steps.
+const (
+	WorkflowAction       = "action"
+	WorkflowSequential   = "sequential"
+	WorkflowJoin         = "join"
+	WorkflowParallelJoin = "parallel-join"
+	WorkflowWhen         = "when"
+	WorkflowUnless       = "unless"
+	WorkflowTimeout      = "timeout"
+)
+
+// WorkflowStep is a step of the workflow, either an action or a composition of the steps.
+type WorkflowStep struct {
+	Kind string `json:"kind"`
+	// Action is the name of the action of the action step.
+	Action string `json:"action,omitempty"`
+	// Arg is the argument of the composition, i.e., the predicate of "when" and "unless",
+	// the duration of "timeout", and the optional limit of "parallel-join".
+	Arg   string         `json:"arg,omitempty"`
+	Steps []WorkflowStep `json:"steps,omitempty"`
+	Pos   Pos            `json:"-"`
+	End   Pos            `json:"-"`
+}
+
+// WorkflowDeclaration declares how the actions are composed into the reconcile workflow.
+// The steps run sequentially, and the deferred actions run after them regardless of the
+// results.
+type WorkflowDeclaration struct {
+	Comments []string       `json:"comments"`
+	Steps    []WorkflowStep `json:"steps"`
+	Deferred []string       `json:"deferred,omitempty"`
+	Pos      Pos            `json:"-"`
+	End      Pos            `json:"-"`
+
+	// DeferredPos are the positions of the deferred actions.
+	DeferredPos []Pos `json:"-"`
+}
+
+// Predicates returns the names of the predicates used by the workflow, in the order of
+// first use.
+func (d *WorkflowDeclaration) Predicates() []string {
+	var predicates []string
+	var visit func(steps []WorkflowStep)
+	visit = func(steps []WorkflowStep) {
+		for _, step := range steps {
+			if (step.Kind == WorkflowWhen || step.Kind == WorkflowUnless) && !lo.Contains(predicates, step.Arg) {
+				predicates = append(predicates, step.Arg)
+			}
+			visit(step.Steps)
+		}
+	}
+	visit(d.Steps)
+	return predicates
 }
 
 type ControllerManagerDeclaration struct {
@@ -91,6 +272,57 @@ type ControllerManagerDeclaration struct {
 	States     map[string]StateDeclaration  `json:"states"`
 	Actions    []ActionDeclaration          `json:"actions"`
 	ActionMap  map[string]ActionDeclaration `json:"-"`
+	Finalizer  *FinalizerDeclaration        `json:"finalizer,omitempty"`
+	Workflow   *WorkflowDeclaration         `json:"workflow,omitempty"`
+	Pos        Pos                          `json:"-"`
+	End        Pos                          `json:"-"`
+
+	// StateBlocks and ActionBlocks are the spans of the state and the action blocks, and
+	// ApplyPos and ClusterScopedPos are the positions of the statements if declared.
+	StateBlocks      []Span `json:"-"`
+	ActionBlocks     []Span `json:"-"`
+	ApplyPos         Pos    `json:"-"`
+	ClusterScopedPos Pos    `json:"-"`
+
+	// Apply tells if the states are synced with server-side apply by default.
+	Apply bool `json:"apply,omitempty"`
+	// FieldManager is the field manager of server-side apply. It defaults to the name.
+	FieldManager string `json:"field_manager,omitempty"`
+	// NoForce tells if server-side apply doesn't force the ownership of the fields, so that
+	// conflicts with other field managers are reported.
+	NoForce bool `json:"no_force,omitempty"`
+
+	// ClusterScoped tells if the target is of a cluster-scoped type.
+	ClusterScoped bool `json:"cluster_scoped,omitempty"`
+}
+
+// UsesApply tells if the state is synced with server-side apply, either declared on the
+// state or on the manager.
+func (d *ControllerManagerDeclaration) UsesApply(state *StateDeclaration) bool {
+	_, ok := state.Selectors["apply"]
+	return ok || d.Apply
+}
+
+// ForcesApply tells if server-side apply of the state forces the ownership of the fields.
+// It follows "apply=force" or "apply=noforce" on the state, or "noforce" on the manager,
+// and forces by default.
+func (d *ControllerManagerDeclaration) ForcesApply(state *StateDeclaration) bool {
+	switch state.Selectors["apply"] {
+	case "force":
+		return true
+	case "noforce":
+		return false
+	default:
+		return !d.NoForce
+	}
+}
+
+// GetFieldManager returns the field manager of server-side apply.
+func (d *ControllerManagerDeclaration) GetFieldManager() string {
+	if d.FieldManager == "" {
+		return d.Name
+	}
+	return d.FieldManager
 }
 
 func (d *ControllerManagerDeclaration) AddStateDeclaration(s StateDeclaration) bool {
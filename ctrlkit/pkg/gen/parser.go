@@ -5,10 +5,15 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"go/token"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -19,6 +24,14 @@ var (
 	errInvalidDeclBlock  = errors.New("invalid decl block")
 	errInvalidStateBlock = errors.New("invalid state block")
 	errInvalidActionStmt = errors.New("invalid action block")
+	errInvalidFinalizer  = errors.New("invalid finalizer block")
+	errInvalidBuilder    = errors.New("builder requires an owned non-array state")
+	errInvalidApply      = errors.New("invalid apply statement")
+	errInvalidPruner     = errors.New("pruner requires an owned array state")
+	errInvalidScope      = errors.New("invalid namespace scope")
+	errInvalidLabelReq   = errors.New("invalid label requirement")
+	errInvalidWorkflow   = errors.New("invalid workflow block")
+	errActionNotFound    = errors.New("action not found")
 )
 
 func parseGvk(gvk string) (schema.GroupVersionKind, error) {
@@ -105,6 +118,180 @@ func parseDeclOpen(words []string) (name string, targetType string, err error) {
 	return
 }
 
+func parseFinalizerOpen(words []string) (name string, err error) {
+	if len(words) != 3 || !isBeginBracket(words[2:]) {
+		return "", errInvalidFinalizer
+	}
+	return words[1], nil
+}
+
+// parseActionDeclaration parses the action declaration in line, e.g., "Action(state1, state2)".
+// An error is returned if it's not valid, and the params are validated against the
+// states declared in decl. The name and params are still returned if some params aren't
+// declared.
+func parseActionDeclaration(line string, decl *ControllerManagerDeclaration) (name string, params []string, err error) {
+	if line[0] == '(' || !strings.Contains(line, "(") || line[len(line)-1] != ')' {
+		return "", nil, errInvalidActionStmt
+	}
+	splits := strings.SplitN(line[:len(line)-1], "(", 2)
+	if strings.ContainsAny(splits[1], "()") {
+		return "", nil, errInvalidActionStmt
+	}
+	name, params = splits[0], lo.Map(strings.Split(splits[1], ","), func(s string, i int) string {
+		return strings.TrimSpace(s)
+	})
+	if len(params) == 1 && params[0] == "" {
+		params = nil
+	} else {
+		for _, param := range params {
+			if !decl.ContainsState(param) {
+				return name, params, errTypeNotFound
+			}
+		}
+	}
+	return name, params, nil
+}
+
+// parseLabelRequirement parses the set-based label requirement in line, e.g.,
+// "labels/tier in (a,b)", "labels/!legacy" and "labels/app exists", into the canonical
+// form. It returns false if the line isn't one, e.g., "labels/app=example".
+func parseLabelRequirement(line string) (string, bool, error) {
+	if !strings.HasPrefix(line, "labels/") {
+		return "", false, nil
+	}
+	expr := line[len("labels/"):]
+	words := splitLineIntoWords(expr)
+	if len(words) == 1 && !strings.HasPrefix(expr, "!") && !strings.Contains(expr, "!=") {
+		return "", false, nil
+	}
+	if len(words) == 2 && words[1] == "exists" {
+		expr = words[0]
+	}
+
+	requirements, err := labels.ParseToRequirements(expr)
+	if err != nil {
+		return "", true, fmt.Errorf("%w: %s", errInvalidLabelReq, err)
+	}
+	if len(requirements) != 1 {
+		return "", true, errInvalidLabelReq
+	}
+	return requirements[0].String(), true, nil
+}
+
+// validateStateScope validates the namespace scope of the state of decl. At most one of
+// "namespace", "cluster-scoped" and "all-namespaces" could be selected. States of a
+// cluster-scoped target must select their namespaces explicitly, as the target has none,
+// and states owned by a namespaced target must be in the namespace of the target.
+func validateStateScope(decl *ControllerManagerDeclaration, state *StateDeclaration) error {
+	scopes := lo.Filter([]string{"namespace", "cluster-scoped", "all-namespaces"}, func(k string, _ int) bool {
+		_, ok := state.Selectors[k]
+		return ok
+	})
+	if len(scopes) > 1 {
+		return errInvalidScope
+	}
+	namespace, namespaced := state.Namespace()
+	if namespace, ok := state.Selectors["namespace"]; ok && namespace == "" {
+		return errInvalidScope
+	}
+	if state.Selectors["cluster-scoped"] != "" || state.Selectors["all-namespaces"] != "" {
+		return errInvalidScope
+	}
+	if _, named := state.Selectors["name"]; named && state.IsInAllNamespaces() {
+		return errInvalidScope
+	}
+	if !decl.ClusterScoped {
+		if _, owned := state.Selectors["owned"]; owned && (!namespaced || namespace != "${target.Namespace}") {
+			return errInvalidScope
+		}
+	} else if namespaced && strings.Contains(namespace, "${target.Namespace}") {
+		return errInvalidScope
+	}
+	return nil
+}
+
+// parseWorkflowStepOpen parses the opening of the composition step of the workflow, e.g.,
+// "join {", "when Predicate {" and "timeout 30s {".
+func parseWorkflowStepOpen(words []string) (WorkflowStep, error) {
+	if !opensBlock(words) {
+		return WorkflowStep{}, errInvalidWorkflow
+	}
+	args := words[1 : len(words)-1]
+	step := WorkflowStep{Kind: words[0]}
+	switch step.Kind {
+	case WorkflowSequential, WorkflowJoin:
+		if len(args) != 0 {
+			return WorkflowStep{}, errInvalidWorkflow
+		}
+	case WorkflowParallelJoin:
+		if len(args) > 1 {
+			return WorkflowStep{}, errInvalidWorkflow
+		}
+		if len(args) == 1 {
+			if limit, err := strconv.Atoi(args[0]); err != nil || limit <= 0 {
+				return WorkflowStep{}, fmt.Errorf("%w: invalid limit %s", errInvalidWorkflow, args[0])
+			}
+			step.Arg = args[0]
+		}
+	case WorkflowWhen, WorkflowUnless:
+		if len(args) != 1 || !token.IsIdentifier(args[0]) {
+			return WorkflowStep{}, fmt.Errorf("%w: invalid predicate", errInvalidWorkflow)
+		}
+		step.Arg = args[0]
+	case WorkflowTimeout:
+		if len(args) != 1 {
+			return WorkflowStep{}, errInvalidWorkflow
+		}
+		if d, err := time.ParseDuration(args[0]); err != nil || d <= 0 {
+			return WorkflowStep{}, fmt.Errorf("%w: invalid timeout %s", errInvalidWorkflow, args[0])
+		}
+		step.Arg = args[0]
+	default:
+		return WorkflowStep{}, errInvalidWorkflow
+	}
+	return step, nil
+}
+
+// parseWorkflowActionRef parses the reference of the action in the workflow, e.g., "Action()".
+func parseWorkflowActionRef(s string) (string, error) {
+	if !strings.HasSuffix(s, "()") || !token.IsIdentifier(s[:len(s)-2]) {
+		return "", errInvalidWorkflow
+	}
+	return s[:len(s)-2], nil
+}
+
+// isActionDeclared tells if the action is declared in decl, or generated, e.g., "Sync<State>"
+// and "Finalizer".
+func isActionDeclared(decl *ControllerManagerDeclaration, name string) bool {
+	if _, ok := decl.ActionMap[name]; ok {
+		return true
+	}
+	if name == "Finalizer" {
+		return decl.Finalizer != nil
+	}
+	for _, state := range decl.States {
+		if lo.Contains(state.GeneratedActionNames(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// implMethodNames returns the names of the methods of the impl interface besides the
+// actions, i.e., the builders and the pruners.
+func implMethodNames(decl *ControllerManagerDeclaration) []string {
+	var names []string
+	for _, state := range decl.States {
+		if builder, ok := state.Builder(); ok {
+			names = append(names, builder)
+		}
+		if pruner, ok := state.Pruner(); ok {
+			names = append(names, pruner)
+		}
+	}
+	return names
+}
+
 func isBeginBracket(words []string) bool {
 	return len(words) == 1 && words[0] == "{"
 }
@@ -113,13 +300,60 @@ func isEndBracket(words []string) bool {
 	return len(words) == 1 && words[0] == "}"
 }
 
+// ParseOptions are the options of parsing the document.
+type ParseOptions struct {
+	// FileName is the name of the document, set to the document and the errors.
+	FileName string
+	// AllErrors tells the parser to recover from errors and report all of them as ParseErrors,
+	// together with the partially parsed document. Otherwise, it stops at the first error,
+	// which is a *ParseError.
+	AllErrors bool
+}
+
+// position is the position of a token in the document.
+type position struct {
+	line  int
+	raw   string
+	token string
+}
+
+// column returns the column of the token, which starts a word or follows "[]", "(" or ",".
+func (p position) column() int {
+	if p.token != "" {
+		for i := 0; i+len(p.token) <= len(p.raw); i++ {
+			if strings.HasPrefix(p.raw[i:], p.token) && (i == 0 || strings.ContainsRune(" \t](,", rune(p.raw[i-1]))) {
+				return i + 1
+			}
+		}
+	}
+	return len(p.raw) - len(strings.TrimLeft(p.raw, " \t")) + 1
+}
+
+// pos returns the position of the token as a Pos.
+func (p position) pos() Pos {
+	return Pos{Line: p.line, Column: p.column()}
+}
+
+// opensBlock tells if the words open a block, i.e., end with "{".
+func opensBlock(words []string) bool {
+	return len(words) > 0 && words[len(words)-1] == "{"
+}
+
 // ParseDoc parses the bytes from reader into a structured ControllerManagerDocument
-// when possible.
+// when possible. It stops at the first error, which is a *ParseError.
 func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
+	return ParseDocWithOptions(r, ParseOptions{})
+}
+
+// ParseDocWithOptions parses the bytes from reader into a structured
+// ControllerManagerDocument with the options.
+func ParseDocWithOptions(r io.Reader, opts ParseOptions) (*ControllerManagerDocument, error) {
 	doc := &ControllerManagerDocument{
+		FileName: opts.FileName,
 		GvReflections: GvReflections{
 			GvPkgBinds: make(map[string]GvBind),
 			GvkAliases: make(map[string]string),
+			AliasPos:   make(map[string]Pos),
 		},
 		Decls: make(map[string]ControllerManagerDeclaration),
 	}
@@ -129,17 +363,85 @@ func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
 	var comments []string
 	var lastIsEmpty bool
 	var lineNo int
-	var inDecl, inState, inActions, inStateDecl bool
+	var inDecl, inState, inActions, inStateDecl, inFinalizer, inWorkflow bool
 	var decl *ControllerManagerDeclaration
 	var stateDecl *StateDeclaration
+	var finalizerDecl *FinalizerDeclaration
+
+	// Open steps of the workflow, the bottom of which is the workflow itself.
+	var workflowComments []string
+	var workflowStack []WorkflowStep
+	var workflowStackPos []position
+	var workflowDeferred []string
+	var workflowDeferredPos []Pos
+	var workflowActionPos, workflowPredicatePos map[string]position
+
+	// Positions of the decl, the state, its selectors, the scopes of the states and the
+	// actions for errors reported when the blocks close.
+	var declPos, statePos position
+	var selectorPos, stateScopePos, actionPos map[string]position
+
+	// Lines of the block failed to open are skipped until it closes.
+	var skipDepth int
+
+	var errs ParseErrors
+	var pos position
+	report := func(at position, code ErrorCode, msg string, err error) {
+		errs = append(errs, &ParseError{
+			File:   opts.FileName,
+			Line:   at.line,
+			Column: at.column(),
+			Token:  at.token,
+			Code:   code,
+			Msg:    msg,
+			Hint:   codeHints[code],
+			Err:    err,
+		})
+	}
+	// sortErrors sorts the errors by position, since some are reported when the blocks close
+	// at the earlier positions.
+	sortErrors := func() {
+		sort.SliceStable(errs, func(i, j int) bool {
+			if errs[i].Line != errs[j].Line {
+				return errs[i].Line < errs[j].Line
+			}
+			return errs[i].Column < errs[j].Column
+		})
+	}
+	// skip skips the rest of the line, or the block if the line opens one.
+	skip := func(words []string) {
+		if opensBlock(words) {
+			skipDepth = 1
+		}
+		comments = nil
+	}
+	// firstSelectorPos returns the position of the first selector of the keys declared, or
+	// the position of the state.
+	firstSelectorPos := func(keys ...string) position {
+		for _, k := range keys {
+			if p, ok := selectorPos[k]; ok {
+				return p
+			}
+		}
+		return statePos
+	}
 
 	// Parse the document line by line.
 	for scanner.Scan() {
-		line := scanner.Text()
+		// Stop at the first error unless all errors are wanted. The errors in a decl are
+		// checked until it closes, since some of them are reported at the earlier positions
+		// when it closes.
+		if len(errs) > 0 && !opts.AllErrors && !inDecl {
+			sortErrors()
+			return nil, errs[0]
+		}
+
+		raw := scanner.Text()
 		lineNo++
+		pos = position{line: lineNo, raw: raw}
 
 		// Trim leading and tail spaces.
-		line = strings.TrimSpace(line)
+		line := strings.TrimSpace(raw)
 
 		// Skip empty lines.
 		if line == "" {
@@ -149,6 +451,9 @@ func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
 
 		// Do parse.
 		words := splitLineIntoWords(line)
+		at := func(token string) position {
+			return position{line: lineNo, raw: raw, token: token}
+		}
 
 		// Handle comments first. Keep the last continuous block of comments.
 		if strings.HasPrefix(words[0], "//") {
@@ -158,6 +463,18 @@ func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
 			}
 			doubleSlashIndex := strings.Index(line, "//")
 			comments = append(comments, strings.TrimSpace(line[doubleSlashIndex+2:]))
+			doc.Comments = append(doc.Comments, Comment{Pos: at("//").pos(), Text: comments[len(comments)-1]})
+			continue
+		}
+
+		if skipDepth > 0 {
+			if isEndBracket(words) {
+				skipDepth--
+			} else if opensBlock(words) {
+				skipDepth++
+			}
+			comments = nil
+			lastIsEmpty = false
 			continue
 		}
 
@@ -165,40 +482,73 @@ func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
 			if inState {
 				if inStateDecl {
 					if isEndBracket(words) {
+						if _, ok := stateDecl.Builder(); ok {
+							if _, owned := stateDecl.Selectors["owned"]; !owned || stateDecl.IsArray {
+								report(firstSelectorPos("build"), CodeInvalidBuilder, "invalid state block", errInvalidBuilder)
+							}
+						}
+						if _, ok := stateDecl.Pruner(); ok {
+							if _, owned := stateDecl.Selectors["owned"]; !owned || !stateDecl.IsArray {
+								report(firstSelectorPos("prune"), CodeInvalidPruner, "invalid state block", errInvalidPruner)
+							}
+						}
+						if apply, ok := stateDecl.Selectors["apply"]; ok {
+							if _, hasBuilder := stateDecl.Builder(); !hasBuilder || (apply != "" && apply != "force" && apply != "noforce") {
+								report(firstSelectorPos("apply"), CodeInvalidApply, "invalid state block", errInvalidApply)
+							}
+						}
+						if _, named := stateDecl.Selectors["name"]; named && len(stateDecl.LabelRequirements) > 0 {
+							report(firstSelectorPos("labels/"+stateDecl.LabelRequirements[0]), CodeInvalidLabelRequirement, "invalid state block", errInvalidLabelReq)
+						}
+						// The scope is validated when the decl closes, since it depends on
+						// whether the target is cluster-scoped.
+						stateScopePos[stateDecl.Name] = firstSelectorPos("namespace", "cluster-scoped", "all-namespaces", "owned")
+						stateDecl.End = at("}").pos()
 						decl.AddStateDeclaration(*stateDecl)
 
 						comments = nil
 						stateDecl = nil
 						inStateDecl = false
+					} else if requirement, ok, err := parseLabelRequirement(line); ok {
+						if err != nil {
+							report(at(words[0]), CodeInvalidLabelRequirement, "invalid label selector", err)
+						} else if !lo.Contains(stateDecl.LabelRequirements, requirement) {
+							stateDecl.LabelRequirements = append(stateDecl.LabelRequirements, requirement)
+							stateDecl.LabelRequirementPos = append(stateDecl.LabelRequirementPos, at(words[0]).pos())
+							selectorPos["labels/"+requirement] = at(words[0])
+						}
 					} else {
 						if len(words) != 1 {
-							return nil, fmt.Errorf("parse error: invalid state block at line %d: %w", lineNo, errors.New("size not match"))
-						}
-						splits := strings.Split(words[0], "=")
-						if len(splits) > 2 {
-							return nil, fmt.Errorf("parse error: invalid state block at line %d: %w", lineNo, errors.New("invalid selector"))
-						} else if len(splits) == 2 {
-							stateDecl.AddSelector(strings.TrimSpace(splits[0]), strings.TrimSpace(splits[1]))
+							report(at(words[1]), CodeInvalidSelector, "invalid state block", errors.New("size not match"))
+							skip(words)
 						} else {
-							stateDecl.AddSelector(strings.TrimSpace(splits[0]), "")
+							splits := strings.Split(words[0], "=")
+							key := strings.TrimSpace(splits[0])
+							if len(splits) > 2 {
+								report(at(words[0]), CodeInvalidSelector, "invalid state block", errors.New("invalid selector"))
+							} else if len(splits) == 2 && stateDecl.AddSelector(key, strings.TrimSpace(splits[1])) ||
+								len(splits) == 1 && stateDecl.AddSelector(key, "") {
+								stateDecl.SelectorPos[key] = at(words[0]).pos()
+							}
+							selectorPos[key] = at(words[0])
 						}
 					}
 				} else {
 					if isEndBracket(words) {
+						decl.StateBlocks[len(decl.StateBlocks)-1].End = at("}").pos()
 						comments = nil
 						inState = false
+					} else if len(words) != 3 || !isBeginBracket(words[2:]) {
+						report(at(words[0]), CodeInvalidState, "invalid state block", errInvalidStateBlock)
+						skip(words)
 					} else {
-						if len(words) != 3 || !isBeginBracket(words[2:]) {
-							return nil, fmt.Errorf("parse error: invalid state block at line %d: %w", lineNo, errInvalidStateBlock)
-						}
-
 						stateType := words[1]
 						isArray := strings.HasPrefix(stateType, "[]")
 						if isArray {
 							stateType = stateType[2:]
 						}
 						if !doc.DoesAliasExists(stateType) {
-							return nil, fmt.Errorf("parse error: invalid state block at line %d: %w", lineNo, errTypeNotFound)
+							report(at(stateType), CodeTypeNotFound, "invalid state block", errTypeNotFound)
 						}
 
 						// TODO check if state type exists
@@ -209,47 +559,154 @@ func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
 							Type:      stateType,
 							IsArray:   isArray,
 							Selectors: make(map[string]string),
+							Pos:       at(words[0]).pos(),
+
+							SelectorPos: make(map[string]Pos),
+						}
+						if decl.ContainsState(stateDecl.Name) {
+							report(at(words[0]), CodeRedeclaration, "invalid state block", errRedeclaration)
 						}
+						statePos, selectorPos = at(words[0]), make(map[string]position)
 
 						comments = nil
 						inStateDecl = true
 					}
 				}
-			} else if inActions {
+			} else if inWorkflow {
+				top := len(workflowStack) - 1
 				if isEndBracket(words) {
-					comments = nil
-					inActions = false
-				} else {
-					if line[0] == '(' || !strings.Contains(line, "(") || line[len(line)-1] != ')' {
-						return nil, fmt.Errorf("parse error: invalid action declaration at line %d", lineNo)
+					step, stepPos := workflowStack[top], workflowStackPos[top]
+					workflowStack, workflowStackPos = workflowStack[:top], workflowStackPos[:top]
+					if len(step.Steps) == 0 {
+						report(stepPos, CodeInvalidWorkflow, "invalid workflow block", fmt.Errorf("%w: empty block", errInvalidWorkflow))
 					}
-					splits := strings.SplitN(line[:len(line)-1], "(", 2)
-					if strings.ContainsAny(splits[1], "()") {
-						return nil, fmt.Errorf("parse error: invalid action declaration at line %d", lineNo)
+					step.End = at("}").pos()
+					if top == 0 {
+						decl.Workflow = &WorkflowDeclaration{
+							Comments: workflowComments,
+							Steps:    step.Steps,
+							Deferred: workflowDeferred,
+							Pos:      step.Pos,
+							End:      step.End,
+
+							DeferredPos: workflowDeferredPos,
+						}
+						inWorkflow = false
+					} else {
+						workflowStack[top-1].Steps = append(workflowStack[top-1].Steps, step)
 					}
-					name, params := splits[0], lo.Map(strings.Split(splits[1], ","), func(s string, i int) string {
-						return strings.TrimSpace(s)
-					})
-					if len(params) == 1 && params[0] == "" {
-						params = nil
+				} else if opensBlock(words) {
+					step, err := parseWorkflowStepOpen(words)
+					if err != nil {
+						report(at(words[0]), CodeInvalidWorkflow, "invalid workflow block", err)
+						skip(words)
 					} else {
-						for _, param := range params {
-							if !decl.ContainsState(param) {
-								return nil, fmt.Errorf("parse error: invalid action declaration at line %d: %w", lineNo, errTypeNotFound)
+						step.Pos = at(words[0]).pos()
+						workflowStack, workflowStackPos = append(workflowStack, step), append(workflowStackPos, at(words[0]))
+						if step.Kind == WorkflowWhen || step.Kind == WorkflowUnless {
+							if _, ok := workflowPredicatePos[step.Arg]; !ok {
+								workflowPredicatePos[step.Arg] = at(step.Arg)
 							}
 						}
 					}
-					if !decl.AddActionDeclaration(ActionDeclaration{
+				} else if words[0] == "defer" {
+					if top != 0 || len(words) != 2 {
+						report(at(words[0]), CodeInvalidWorkflow, "invalid workflow block", fmt.Errorf("%w: defer is only allowed at the top", errInvalidWorkflow))
+					} else if name, err := parseWorkflowActionRef(words[1]); err != nil {
+						report(at(words[1]), CodeInvalidWorkflow, "invalid workflow block", err)
+					} else {
+						workflowDeferred = append(workflowDeferred, name)
+						workflowDeferredPos = append(workflowDeferredPos, at(words[0]).pos())
+						if _, ok := workflowActionPos[name]; !ok {
+							workflowActionPos[name] = at(name)
+						}
+					}
+				} else if name, err := parseWorkflowActionRef(line); err != nil {
+					report(at(words[0]), CodeInvalidWorkflow, "invalid workflow block", err)
+				} else {
+					workflowStack[top].Steps = append(workflowStack[top].Steps, WorkflowStep{Kind: WorkflowAction, Action: name, Pos: at(words[0]).pos()})
+					if _, ok := workflowActionPos[name]; !ok {
+						workflowActionPos[name] = at(name)
+					}
+				}
+				comments = nil
+			} else if inActions || inFinalizer {
+				if isEndBracket(words) {
+					if inFinalizer {
+						finalizerDecl.End = at("}").pos()
+						decl.Finalizer = finalizerDecl
+						finalizerDecl = nil
+					} else {
+						decl.ActionBlocks[len(decl.ActionBlocks)-1].End = at("}").pos()
+					}
+					comments = nil
+					inActions, inFinalizer = false, false
+				} else {
+					name, params, err := parseActionDeclaration(line, decl)
+					if err != nil {
+						code, token := CodeInvalidAction, words[0]
+						if errors.Is(err, errTypeNotFound) {
+							code, token = CodeStateNotFound, lo.FindOrElse(params, "", func(p string) bool {
+								return !decl.ContainsState(p)
+							})
+						}
+						report(at(token), code, "invalid action declaration", err)
+					} else if !decl.AddActionDeclaration(ActionDeclaration{
 						Comments: comments,
 						Name:     name,
 						Params:   params,
+						Pos:      at(name).pos(),
 					}) {
-						return nil, fmt.Errorf("parse error: invalid action declaration at line %d: %w", lineNo, errRedeclaration)
+						report(at(name), CodeRedeclaration, "invalid action declaration", errRedeclaration)
+					} else {
+						actionPos[name] = at(name)
+						if inFinalizer {
+							finalizerDecl.Actions = append(finalizerDecl.Actions, name)
+						}
 					}
 					comments = nil
 				}
 			} else {
 				if isEndBracket(words) {
+					// Generated actions must not collide with the declared ones.
+					stateNames := lo.Keys(decl.States)
+					sort.Strings(stateNames)
+					for _, stateName := range stateNames {
+						state := decl.States[stateName]
+						if err := validateStateScope(decl, &state); err != nil {
+							report(stateScopePos[stateName], CodeInvalidScope, "invalid state block", err)
+						}
+						for _, name := range state.GeneratedActionNames() {
+							if _, ok := decl.ActionMap[name]; ok {
+								report(actionPos[name], CodeRedeclaration, "invalid decl statement", errRedeclaration)
+							}
+						}
+					}
+					// Finalizer() is generated for the finalizer.
+					if _, ok := decl.ActionMap["Finalizer"]; ok && decl.Finalizer != nil {
+						report(actionPos["Finalizer"], CodeRedeclaration, "invalid finalizer block", errRedeclaration)
+					}
+					// Actions of the workflow must be declared or generated, and the predicates
+					// must not collide with the other methods of the impl.
+					if decl.Workflow != nil {
+						actionNames := lo.Keys(workflowActionPos)
+						sort.Strings(actionNames)
+						for _, name := range actionNames {
+							if !isActionDeclared(decl, name) {
+								report(workflowActionPos[name], CodeActionNotFound, "invalid workflow block", errActionNotFound)
+							}
+						}
+						if _, ok := decl.ActionMap["Workflow"]; ok {
+							report(actionPos["Workflow"], CodeRedeclaration, "invalid workflow block", errRedeclaration)
+						}
+						methodNames := implMethodNames(decl)
+						for _, predicate := range decl.Workflow.Predicates() {
+							if _, ok := decl.ActionMap[predicate]; ok || lo.Contains(methodNames, predicate) {
+								report(workflowPredicatePos[predicate], CodeRedeclaration, "invalid workflow block", errRedeclaration)
+							}
+						}
+					}
+					decl.End = at("}").pos()
 					doc.Decls[decl.Name] = *decl
 
 					comments = nil
@@ -259,18 +716,84 @@ func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
 					switch words[0] {
 					case "state":
 						if !isBeginBracket(words[1:]) {
-							return nil, fmt.Errorf("parse error: invalid decl statement at line %d: %w", lineNo, errInvalidStateBlock)
+							report(at(words[0]), CodeInvalidState, "invalid decl statement", errInvalidStateBlock)
+							skip(words)
+							break
 						}
+						decl.StateBlocks = append(decl.StateBlocks, Span{Pos: at(words[0]).pos()})
 						comments = nil
 						inState = true
 					case "action":
 						if !isBeginBracket(words[1:]) {
-							return nil, fmt.Errorf("parse error: invalid decl statement at line %d: %w", lineNo, errInvalidActionStmt)
+							report(at(words[0]), CodeInvalidAction, "invalid decl statement", errInvalidActionStmt)
+							skip(words)
+							break
 						}
+						decl.ActionBlocks = append(decl.ActionBlocks, Span{Pos: at(words[0]).pos()})
 						comments = nil
 						inActions = true
+					case "finalizer":
+						name, err := parseFinalizerOpen(words)
+						if err != nil {
+							report(at(words[0]), CodeInvalidFinalizer, "invalid decl statement", err)
+							skip(words)
+							break
+						}
+						if decl.Finalizer != nil {
+							report(at(words[0]), CodeRedeclaration, "invalid decl statement", errRedeclaration)
+							skip(words)
+							break
+						}
+						finalizerDecl = &FinalizerDeclaration{
+							Comments: comments,
+							Name:     name,
+							Pos:      at(words[0]).pos(),
+						}
+						comments = nil
+						inFinalizer = true
+					case "workflow":
+						if !isBeginBracket(words[1:]) {
+							report(at(words[0]), CodeInvalidWorkflow, "invalid decl statement", errInvalidWorkflow)
+							skip(words)
+							break
+						}
+						if decl.Workflow != nil {
+							report(at(words[0]), CodeRedeclaration, "invalid decl statement", errRedeclaration)
+							skip(words)
+							break
+						}
+						workflowComments, workflowDeferred, workflowDeferredPos = comments, nil, nil
+						workflowStack = []WorkflowStep{{Kind: WorkflowSequential, Pos: at(words[0]).pos()}}
+						workflowStackPos = []position{at(words[0])}
+						comments = nil
+						inWorkflow = true
+					case "apply":
+						args := words[1:]
+						noForce := len(args) > 0 && args[len(args)-1] == "noforce"
+						if noForce {
+							args = args[:len(args)-1]
+						}
+						if len(args) > 1 || decl.Apply {
+							report(at(words[0]), CodeInvalidApply, "invalid decl statement", errInvalidApply)
+							skip(words)
+							break
+						}
+						decl.Apply, decl.ApplyPos, decl.NoForce = true, at(words[0]).pos(), noForce
+						if len(args) == 1 {
+							decl.FieldManager = args[0]
+						}
+						comments = nil
+					case "cluster-scoped":
+						if len(words) != 1 || decl.ClusterScoped {
+							report(at(words[0]), CodeInvalidScope, "invalid decl statement", errInvalidScope)
+							skip(words)
+							break
+						}
+						decl.ClusterScoped, decl.ClusterScopedPos = true, at(words[0]).pos()
+						comments = nil
 					default:
-						return nil, fmt.Errorf("parse error: invalid decl statement at line %d", lineNo)
+						report(at(words[0]), CodeInvalidDecl, "invalid decl statement", nil)
+						skip(words)
 					}
 				}
 			}
@@ -279,38 +802,49 @@ func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
 			case "bind":
 				gv, pkg, gvParsed, err := parseBind(words)
 				if err != nil {
-					return nil, fmt.Errorf("parse error: invalid bind statement at line %d: %w", lineNo, err)
-				}
-				if !doc.AddGvBind(gv, pkg, gvParsed) {
-					return nil, fmt.Errorf("parse error: invalid bind statement at line %d: %w", lineNo, errRedeclaration)
+					report(at(words[0]), CodeInvalidBind, "invalid bind statement", err)
+				} else if !doc.AddGvBind(gv, pkg, gvParsed) {
+					report(at(gv), CodeRedeclaration, "invalid bind statement", errRedeclaration)
+				} else {
+					bind := doc.GvPkgBinds[gv]
+					bind.Pos = at(gv).pos()
+					doc.GvPkgBinds[gv] = bind
 				}
 				comments = nil
 			case "alias":
 				gvk, alias, err := parseAlias(words)
 				if err != nil {
-					return nil, fmt.Errorf("parse error: invalid alias statement at line %d: %w", lineNo, err)
+					report(at(words[0]), CodeInvalidAlias, "invalid alias statement", err)
+					comments = nil
+					break
 				}
 				gvkParsed, err := parseGvk(gvk)
 				if err != nil {
-					return nil, fmt.Errorf("parse error: invalid alias statement at line %d: %w", lineNo, err)
-				}
-				if !doc.IsGvBound(gvkParsed.GroupVersion().String()) {
-					return nil, fmt.Errorf("parse error: invalid alias statement at line %d: %w", lineNo, errTypeNotFound)
-				}
-				if !doc.AddGvkAliases(gvk, alias) {
-					return nil, fmt.Errorf("parse error: invalid alias statement at line %d: %w", lineNo, errRedeclaration)
+					report(at(gvk), CodeInvalidAlias, "invalid alias statement", err)
+				} else if !doc.IsGvBound(gvkParsed.GroupVersion().String()) {
+					report(at(gvk), CodeBindNotFound, "invalid alias statement", errTypeNotFound)
+				} else if !doc.AddGvkAliases(gvk, alias) {
+					report(at(alias), CodeRedeclaration, "invalid alias statement", errRedeclaration)
+				} else {
+					doc.AliasPos[alias] = at(alias).pos()
 				}
 				comments = nil
 			case "decl":
 				name, targetType, err := parseDeclOpen(words)
 				if err != nil {
-					return nil, fmt.Errorf("parse error: invalid decl statement at line %d: %w", lineNo, err)
+					report(at(words[0]), CodeInvalidDecl, "invalid decl statement", err)
+					skip(words)
+					break
 				}
 				if doc.DoesControllerManagerDeclarationExists(name) {
-					return nil, fmt.Errorf("parse error: invalid decl statement at line %d: %w", lineNo, errRedeclaration)
+					report(at(name), CodeRedeclaration, "invalid decl statement", errRedeclaration)
+					skip(words)
+					break
 				}
 				if !doc.IsGvBound(targetType) && !doc.DoesAliasExists(targetType) {
-					return nil, fmt.Errorf("parse error: invalid decl statement at line %d: %w", lineNo, errBindNotFound)
+					report(at(targetType), CodeBindNotFound, "invalid decl statement", errBindNotFound)
+					skip(words)
+					break
 				}
 
 				inDecl = true
@@ -321,15 +855,19 @@ func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
 					States:     make(map[string]StateDeclaration),
 					Actions:    nil,
 					ActionMap:  make(map[string]ActionDeclaration),
+					Pos:        at(name).pos(),
 				}
 				// decl.AddStateDeclaration(StateDeclaration{
 				// 	Comments: nil,
 				// 	Name:     "target",
 				// 	Type:     targetType,
 				// })
+				declPos, stateScopePos, actionPos = at(name), make(map[string]position), make(map[string]position)
+				workflowActionPos, workflowPredicatePos = make(map[string]position), make(map[string]position)
 				comments = nil
 			default:
-				return nil, fmt.Errorf("parse error: invalid statement at line %d", lineNo)
+				report(at(words[0]), CodeUnknownStatement, "invalid statement", nil)
+				skip(words)
 			}
 		}
 
@@ -340,5 +878,19 @@ func ParseDoc(r io.Reader) (*ControllerManagerDocument, error) {
 		return nil, fmt.Errorf("parse error: %w", err)
 	}
 
+	// Blocks must be closed at the end.
+	if inDecl {
+		report(declPos, CodeUnclosedBlock, "invalid decl statement", errors.New("unexpected end of document"))
+	} else if skipDepth > 0 {
+		report(pos, CodeUnclosedBlock, "invalid statement", errors.New("unexpected end of document"))
+	}
+
+	if len(errs) > 0 {
+		sortErrors()
+		if !opts.AllErrors {
+			return nil, errs[0]
+		}
+		return doc, errs
+	}
 	return doc, nil
 }
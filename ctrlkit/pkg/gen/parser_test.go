@@ -3,8 +3,10 @@ package gen
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -23,3 +25,603 @@ func Test_ParseDoc(t *testing.T) {
 	b, _ := json.MarshalIndent(doc, "", "  ")
 	fmt.Println(string(b))
 }
+
+const testDocHeader = `bind v1 k8s.io/api/core/v1
+bind batch/v1 k8s.io/api/batch/v1
+
+alias Pod v1/Pod
+alias Node v1/Node
+alias Job batch/v1/Job
+`
+
+func parseTestDoc(t *testing.T, body string) *ControllerManagerDocument {
+	doc, err := ParseDoc(strings.NewReader(testDocHeader + body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func Test_ParseDoc_Finalizer(t *testing.T) {
+	doc := parseTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+        }
+    }
+
+    action {
+        Sync(pods)
+    }
+
+    // Clean up the pods.
+    finalizer example.io/cleanup {
+        DeletePods(pods)
+        Forget()
+    }
+}
+`)
+
+	decl := doc.Decls["JobManager"]
+	if decl.Finalizer == nil || decl.Finalizer.Name != "example.io/cleanup" {
+		t.Fatal("finalizer is not parsed")
+	}
+	if len(decl.Finalizer.Comments) != 1 || decl.Finalizer.Comments[0] != "Clean up the pods." {
+		t.Fatal("comments of finalizer are not parsed")
+	}
+	if len(decl.Finalizer.Actions) != 2 || decl.Finalizer.Actions[0] != "DeletePods" || decl.Finalizer.Actions[1] != "Forget" {
+		t.Fatal("actions of finalizer are not parsed")
+	}
+	if len(decl.Actions) != 3 {
+		t.Fatal("actions of finalizer should be declared in the manager")
+	}
+
+	for _, body := range []string{
+		"decl JobManager for Job {\n finalizer a {\n }\n finalizer b {\n }\n}",
+		"decl JobManager for Job {\n finalizer {\n }\n}",
+		"decl JobManager for Job {\n finalizer a {\n Clean(pods)\n }\n}",
+	} {
+		if _, err := ParseDoc(strings.NewReader(testDocHeader + body)); err == nil {
+			t.Fatalf("should fail to parse: %s", body)
+		}
+	}
+
+	// The action collides with Finalizer() generated for the finalizer.
+	_, err := ParseDoc(strings.NewReader(testDocHeader + "decl JobManager for Job {\n finalizer a {\n Clean()\n }\n action {\n Finalizer()\n }\n}"))
+	if perr, ok := err.(*ParseError); !ok || perr.Code != CodeRedeclaration || perr.Line != 12 || perr.Token != "Finalizer" {
+		t.Fatalf("should fail to parse with %s: %v", CodeRedeclaration, err)
+	}
+}
+
+func Test_ParseDoc_Builder(t *testing.T) {
+	doc := parseTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pod Pod {
+            name=${target.Name}-pod
+            owned
+            build
+        }
+        sidecar Pod {
+            labels/sidecar=${target.Name}
+            owned
+            build=NewSidecar
+        }
+    }
+}
+`)
+
+	decl := doc.Decls["JobManager"]
+	pod, sidecar := decl.States["pod"], decl.States["sidecar"]
+	if builder, ok := pod.Builder(); !ok || builder != "BuildPod" || pod.SyncActionName() != "SyncPod" {
+		t.Fatal("default builder is not parsed")
+	}
+	if builder, ok := sidecar.Builder(); !ok || builder != "NewSidecar" {
+		t.Fatal("named builder is not parsed")
+	}
+
+	for _, body := range []string{
+		"decl JobManager for Job {\n state {\n pod Pod {\n name=a\n build\n }\n }\n}",
+		"decl JobManager for Job {\n state {\n pods []Pod {\n owned\n build\n }\n }\n}",
+		"decl JobManager for Job {\n state {\n pod Pod {\n name=a\n owned\n build\n }\n }\n action {\n SyncPod()\n }\n}",
+	} {
+		if _, err := ParseDoc(strings.NewReader(testDocHeader + body)); err == nil {
+			t.Fatalf("should fail to parse: %s", body)
+		}
+	}
+}
+
+func Test_ParseDoc_Apply(t *testing.T) {
+	doc := parseTestDoc(t, `
+decl JobManager for Job {
+    apply example-controller
+
+    state {
+        pod Pod {
+            name=${target.Name}-pod
+            owned
+            build
+        }
+    }
+}
+
+decl PodManager for Pod {
+    state {
+        job Job {
+            name=${target.Name}-job
+            owned
+            build
+            apply
+        }
+        sidecar Pod {
+            name=${target.Name}-sidecar
+            owned
+            build
+        }
+    }
+}
+`)
+
+	jobMgr, podMgr := doc.Decls["JobManager"], doc.Decls["PodManager"]
+	pod := jobMgr.States["pod"]
+	if !jobMgr.UsesApply(&pod) || jobMgr.GetFieldManager() != "example-controller" {
+		t.Fatal("apply of decl is not parsed")
+	}
+	job, sidecar := podMgr.States["job"], podMgr.States["sidecar"]
+	if !podMgr.UsesApply(&job) || podMgr.UsesApply(&sidecar) || podMgr.GetFieldManager() != "PodManager" {
+		t.Fatal("apply of state is not parsed")
+	}
+
+	for _, body := range []string{
+		"decl JobManager for Job {\n apply\n apply\n}",
+		"decl JobManager for Job {\n apply a b\n}",
+		"decl JobManager for Job {\n state {\n pod Pod {\n name=a\n owned\n apply\n }\n }\n}",
+		"decl JobManager for Job {\n state {\n pod Pod {\n name=a\n owned\n build\n apply=a\n }\n }\n}",
+	} {
+		if _, err := ParseDoc(strings.NewReader(testDocHeader + body)); err == nil {
+			t.Fatalf("should fail to parse: %s", body)
+		}
+	}
+}
+
+func Test_ParseDoc_ApplyNoForce(t *testing.T) {
+	doc := parseTestDoc(t, `
+decl JobManager for Job {
+    apply example-controller noforce
+
+    state {
+        pod Pod {
+            name=${target.Name}-pod
+            owned
+            build
+        }
+        sidecar Pod {
+            name=${target.Name}-sidecar
+            owned
+            build
+            apply=force
+        }
+    }
+}
+
+decl PodManager for Pod {
+    state {
+        job Job {
+            name=${target.Name}-job
+            owned
+            build
+            apply=noforce
+        }
+    }
+}
+`)
+
+	jobMgr, podMgr := doc.Decls["JobManager"], doc.Decls["PodManager"]
+	pod, sidecar := jobMgr.States["pod"], jobMgr.States["sidecar"]
+	if !jobMgr.NoForce || jobMgr.GetFieldManager() != "example-controller" {
+		t.Fatal("noforce of decl is not parsed")
+	}
+	if jobMgr.ForcesApply(&pod) || !jobMgr.ForcesApply(&sidecar) {
+		t.Fatal("force of states in decl is not correct")
+	}
+	job := podMgr.States["job"]
+	if !podMgr.UsesApply(&job) || podMgr.ForcesApply(&job) {
+		t.Fatal("noforce of state is not parsed")
+	}
+
+	doc = parseTestDoc(t, "decl JobManager for Job {\n apply noforce\n}")
+	if jobMgr := doc.Decls["JobManager"]; !jobMgr.NoForce || jobMgr.GetFieldManager() != "JobManager" {
+		t.Fatal("noforce without field manager is not parsed")
+	}
+
+	for _, body := range []string{
+		"decl JobManager for Job {\n apply a b noforce\n}",
+		"decl JobManager for Job {\n apply noforce a\n}",
+	} {
+		if _, err := ParseDoc(strings.NewReader(testDocHeader + body)); err == nil {
+			t.Fatalf("should fail to parse: %s", body)
+		}
+	}
+}
+
+func Test_ParseDoc_Pruner(t *testing.T) {
+	doc := parseTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+            owned
+            prune
+        }
+        sidecars []Pod {
+            labels/sidecar=${target.Name}
+            owned
+            prune=KeepSidecars
+        }
+    }
+}
+`)
+
+	decl := doc.Decls["JobManager"]
+	pods, sidecars := decl.States["pods"], decl.States["sidecars"]
+	if pruner, ok := pods.Pruner(); !ok || pruner != "DesiredPods" || pods.PruneActionName() != "PrunePods" {
+		t.Fatal("default pruner is not parsed")
+	}
+	if pruner, ok := sidecars.Pruner(); !ok || pruner != "KeepSidecars" {
+		t.Fatal("named pruner is not parsed")
+	}
+
+	for _, body := range []string{
+		"decl JobManager for Job {\n state {\n pods []Pod {\n prune\n }\n }\n}",
+		"decl JobManager for Job {\n state {\n pod Pod {\n name=a\n owned\n prune\n }\n }\n}",
+		"decl JobManager for Job {\n state {\n pods []Pod {\n owned\n prune\n }\n }\n action {\n PrunePods()\n }\n}",
+	} {
+		if _, err := ParseDoc(strings.NewReader(testDocHeader + body)); err == nil {
+			t.Fatalf("should fail to parse: %s", body)
+		}
+	}
+}
+
+func Test_ParseDoc_Scope(t *testing.T) {
+	doc := parseTestDoc(t, `
+decl NodeManager for Node {
+    cluster-scoped
+
+    state {
+        pods []Pod {
+            fields/spec.nodeName=${target.Name}
+            all-namespaces
+        }
+        agent Pod {
+            name=agent-${target.Name}
+            namespace=kube-system
+            owned
+        }
+    }
+}
+`)
+
+	decl := doc.Decls["NodeManager"]
+	if !decl.ClusterScoped {
+		t.Fatal("cluster-scoped target is not parsed")
+	}
+	pods, agent := decl.States["pods"], decl.States["agent"]
+	if _, namespaced := pods.Namespace(); namespaced || !pods.IsInAllNamespaces() {
+		t.Fatal("state in all namespaces is not parsed")
+	}
+	if namespace, namespaced := agent.Namespace(); !namespaced || namespace != "kube-system" {
+		t.Fatal("namespace of state is not parsed")
+	}
+
+	for _, body := range []string{
+		// States of cluster-scoped targets must select the namespaces explicitly.
+		"decl NodeManager for Node {\n cluster-scoped\n state {\n pods []Pod {\n }\n }\n}",
+		"decl NodeManager for Node {\n state {\n pods []Pod {\n }\n }\n cluster-scoped\n}",
+		"decl JobManager for Job {\n state {\n pods []Pod {\n namespace=a\n all-namespaces\n }\n }\n}",
+		"decl JobManager for Job {\n state {\n pods []Pod {\n namespace\n }\n }\n}",
+		"decl JobManager for Job {\n state {\n pod Pod {\n name=a\n all-namespaces\n }\n }\n}",
+		// Namespaced targets can't own objects in other namespaces or cluster-scoped ones.
+		"decl JobManager for Job {\n state {\n pods []Pod {\n namespace=a\n owned\n }\n }\n}",
+		"decl JobManager for Job {\n state {\n nodes []Node {\n cluster-scoped\n owned\n }\n }\n}",
+	} {
+		if _, err := ParseDoc(strings.NewReader(testDocHeader + body)); err == nil {
+			t.Fatalf("should fail to parse: %s", body)
+		}
+	}
+
+	// The target could be declared cluster-scoped anywhere in the decl.
+	doc = parseTestDoc(t, "decl NodeManager for Node {\n state {\n pods []Pod {\n namespace=a\n }\n }\n cluster-scoped\n}")
+	if decl := doc.Decls["NodeManager"]; !decl.ClusterScoped {
+		t.Fatal("cluster-scoped after states is not parsed")
+	}
+
+	// Errors of scopes are reported at the states.
+	_, err := ParseDoc(strings.NewReader(testDocHeader + "decl NodeManager for Node {\n state {\n pods []Pod {\n }\n }\n cluster-scoped\n}"))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Code != CodeInvalidScope || parseErr.Token != "pods" {
+		t.Fatalf("error of scope is not correct: %v", err)
+	}
+}
+
+func Test_ParseDoc_LabelRequirements(t *testing.T) {
+	doc := parseTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+            labels/tier in (a, b)
+            labels/track notin (canary)
+            labels/!legacy
+            labels/app exists
+            labels/zone!=z
+        }
+    }
+}
+`)
+
+	pods := doc.Decls["JobManager"].States["pods"]
+	if pods.Selectors["labels/job"] != "${target.Name}" {
+		t.Fatal("equality label is not parsed")
+	}
+	expected := []string{"tier in (a,b)", "track notin (canary)", "!legacy", "app", "zone!=z"}
+	if strings.Join(pods.LabelRequirements, ";") != strings.Join(expected, ";") {
+		t.Fatalf("label requirements are not parsed: %v", pods.LabelRequirements)
+	}
+
+	for body, line := range map[string]int{
+		"decl JobManager for Job {\n state {\n pods []Pod {\n labels/tier in (a,b\n }\n }\n}":          10,
+		"decl JobManager for Job {\n state {\n pods []Pod {\n labels/tier within (a)\n }\n }\n}":       10,
+		"decl JobManager for Job {\n state {\n pods []Pod {\n labels/app exists now\n }\n }\n}":        10,
+		"decl JobManager for Job {\n state {\n pods []Pod {\n labels/!\n }\n }\n}":                     10,
+		"decl JobManager for Job {\n state {\n pods []Pod {\n labels/a in (${target.Name})\n }\n }\n}": 10,
+		"decl JobManager for Job {\n state {\n pod Pod {\n name=a\n labels/!legacy\n }\n }\n}":         11,
+	} {
+		_, err := ParseDoc(strings.NewReader(testDocHeader + body))
+		if err == nil {
+			t.Fatalf("should fail to parse: %s", body)
+		}
+		if perr, ok := err.(*ParseError); !ok || perr.Line != line || perr.Code != CodeInvalidLabelRequirement {
+			t.Fatalf("error should report line %d: %s", line, err)
+		}
+	}
+}
+
+func Test_ParseDoc_Errors(t *testing.T) {
+	_, err := ParseDocWithOptions(strings.NewReader(testDocHeader+`
+decl JobManager for Job {
+    state {
+        pods []Pode {
+        }
+    }
+}
+`), ParseOptions{FileName: "job.cm"})
+
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("error should be a parse error: %v", err)
+	}
+	if perr.File != "job.cm" || perr.Line != 10 || perr.Column != 16 || perr.Token != "Pode" ||
+		perr.Code != CodeTypeNotFound || perr.Hint == "" || !errors.Is(err, errTypeNotFound) {
+		t.Fatalf("parse error is not correct: %#v", perr)
+	}
+	if perr.Error() != "job.cm:10:16: parse error: invalid state block: type not found" {
+		t.Fatalf("message of parse error is not correct: %s", perr)
+	}
+}
+
+func Test_ParseDocWithOptions_AllErrors(t *testing.T) {
+	doc, err := ParseDocWithOptions(strings.NewReader(testDocHeader+`
+decl JobManager for Job {
+    state {
+        pods []Pode {
+            labels/job=${target.Name}
+        }
+        pod Pod {
+            name=a
+            build
+        }
+    }
+
+    unknown {
+        Ignored()
+    }
+
+    action {
+        Forget(jobs)
+        Forget()
+        Forget()
+    }
+}
+
+decl PodManager for Pod {
+`), ParseOptions{AllErrors: true})
+
+	errs, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatalf("errors should be parse errors: %v", err)
+	}
+	var positions []string
+	for _, e := range errs {
+		positions = append(positions, fmt.Sprintf("%s@%d:%d", e.Code, e.Line, e.Column))
+	}
+	expected := []string{
+		"type-not-found@10:16",
+		"invalid-builder@15:13",
+		"invalid-decl@19:5",
+		"state-not-found@24:16",
+		"redeclaration@26:9",
+		"unclosed-block@30:6",
+	}
+	if strings.Join(positions, " ") != strings.Join(expected, " ") {
+		t.Fatalf("errors are not reported: %v", positions)
+	}
+
+	if doc == nil || len(doc.Decls["JobManager"].States) != 2 || len(doc.Decls["JobManager"].Actions) != 1 {
+		t.Fatal("document should be parsed partially")
+	}
+}
+
+func Test_ParseDoc_EarliestError(t *testing.T) {
+	// Both errors are reported when the decl closes, the later one first.
+	_, err := ParseDoc(strings.NewReader(testDocHeader + `
+decl JobManager for Job {
+    state {
+        b []Pod {
+            namespace=x
+            owned
+        }
+        a []Pod {
+            labels/a=b
+            owned
+            prune
+        }
+    }
+
+    action {
+        PruneA()
+    }
+}
+`))
+
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("error should be a parse error: %v", err)
+	}
+	if perr.Code != CodeInvalidScope || perr.Line != 11 {
+		t.Fatalf("the earliest error should be returned: %s", perr)
+	}
+}
+
+func Test_ParseDoc_EarliestError_DeclClose(t *testing.T) {
+	// The scope error is reported when the decl closes, after the action error of a later
+	// line is reported.
+	_, err := ParseDoc(strings.NewReader(testDocHeader + `
+decl JobManager for Job {
+    state {
+        b []Pod {
+            namespace=x
+            owned
+        }
+    }
+
+    action {
+        Foo(zzz)
+    }
+}
+`))
+
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("error should be a parse error: %v", err)
+	}
+	if perr.Code != CodeInvalidScope || perr.Line != 11 {
+		t.Fatalf("the earliest error should be returned: %s", perr)
+	}
+}
+
+func Test_ParseDoc_Workflow(t *testing.T) {
+	doc := parseTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+            owned
+            prune
+        }
+    }
+
+    // Reconcile the job.
+    workflow {
+        Finalizer()
+        join {
+            PrunePods()
+            when Ready {
+                timeout 30s {
+                    Run()
+                }
+            }
+        }
+        parallel-join 2 {
+            Run()
+            unless Ready {
+                Run()
+            }
+        }
+        defer UpdateStatus()
+    }
+
+    action {
+        Run()
+        UpdateStatus()
+    }
+
+    finalizer example.io/cleanup {
+        Forget()
+    }
+}
+`)
+
+	workflow := doc.Decls["JobManager"].Workflow
+	if workflow == nil || len(workflow.Comments) != 1 {
+		t.Fatal("workflow is not parsed")
+	}
+	b, _ := json.Marshal(workflow.Steps)
+	expected := `[{"kind":"action","action":"Finalizer"},` +
+		`{"kind":"join","steps":[{"kind":"action","action":"PrunePods"},{"kind":"when","arg":"Ready","steps":[{"kind":"timeout","arg":"30s","steps":[{"kind":"action","action":"Run"}]}]}]},` +
+		`{"kind":"parallel-join","arg":"2","steps":[{"kind":"action","action":"Run"},{"kind":"unless","arg":"Ready","steps":[{"kind":"action","action":"Run"}]}]}]`
+	if string(b) != expected {
+		t.Fatalf("steps of workflow are not parsed: %s", b)
+	}
+	if strings.Join(workflow.Deferred, ",") != "UpdateStatus" || strings.Join(workflow.Predicates(), ",") != "Ready" {
+		t.Fatal("deferred actions or predicates are not parsed")
+	}
+
+	for body, code := range map[string]ErrorCode{
+		"decl JobManager for Job {\n workflow {\n Run()\n }\n}":                                                 CodeActionNotFound,
+		"decl JobManager for Job {\n workflow {\n Finalizer()\n }\n}":                                           CodeActionNotFound,
+		"decl JobManager for Job {\n workflow {\n join {\n }\n }\n action {\n Run()\n }\n}":                     CodeInvalidWorkflow,
+		"decl JobManager for Job {\n workflow {\n timeout 0s {\n Run()\n }\n }\n action {\n Run()\n }\n}":       CodeInvalidWorkflow,
+		"decl JobManager for Job {\n workflow {\n join {\n defer Run()\n }\n }\n action {\n Run()\n }\n}":       CodeInvalidWorkflow,
+		"decl JobManager for Job {\n workflow {\n Run(pods)\n }\n action {\n Run()\n }\n}":                      CodeInvalidWorkflow,
+		"decl JobManager for Job {\n workflow {\n when Run {\n Run()\n }\n }\n action {\n Run()\n }\n}":         CodeRedeclaration,
+		"decl JobManager for Job {\n workflow {\n Run()\n }\n workflow {\n Run()\n }\n action {\n Run()\n }\n}": CodeRedeclaration,
+	} {
+		_, err := ParseDoc(strings.NewReader(testDocHeader + body))
+		if perr, ok := err.(*ParseError); !ok || perr.Code != code {
+			t.Fatalf("should fail to parse with %s: %s: %v", code, body, err)
+		}
+	}
+}
+
+func Test_ParseDoc_Positions(t *testing.T) {
+	doc := parseTestDoc(t, `
+decl JobManager for Job {
+    state {
+        pods []Pod {
+            labels/job=${target.Name}
+        }
+    }
+
+    action {
+        Sync(pods)
+    }
+}
+`)
+
+	decl := doc.Decls["JobManager"]
+	for name, tc := range map[string]struct {
+		pos      Pos
+		expected Pos
+	}{
+		"bind":   {doc.GvPkgBinds["batch/v1"].Pos, Pos{Line: 2, Column: 6}},
+		"alias":  {doc.AliasPos["Job"], Pos{Line: 6, Column: 7}},
+		"decl":   {decl.Pos, Pos{Line: 8, Column: 6}},
+		"state":  {decl.States["pods"].Pos, Pos{Line: 10, Column: 9}},
+		"action": {decl.ActionMap["Sync"].Pos, Pos{Line: 16, Column: 9}},
+	} {
+		if tc.pos != tc.expected {
+			t.Fatalf("position of %s should be %v, but is %v", name, tc.expected, tc.pos)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+const printIndent = "    "
+
+// printer prints the document canonically, and puts the comments back by the positions
+// of them. Blank lines between the statements are kept, at most one in a row, except
+// those at the beginning and the end of the blocks.
+type printer struct {
+	buf *bytes.Buffer
+	// comments are the comments not printed yet.
+	comments []Comment
+	indent   int
+	// lastLine is the line of the last statement or comment printed.
+	lastLine int
+	// first tells if nothing is printed in the current block yet.
+	first bool
+}
+
+func (p *printer) writeLine(text string) {
+	p.buf.WriteString(strings.Repeat(printIndent, p.indent))
+	p.buf.WriteString(text)
+	p.buf.WriteByte('\n')
+}
+
+// separate writes a blank line before the line if there are blank lines before it.
+func (p *printer) separate(line int) {
+	if !p.first && line > p.lastLine+1 {
+		p.buf.WriteByte('\n')
+	}
+}
+
+func formatComment(text string) string {
+	if text == "" {
+		return "//"
+	}
+	return "// " + text
+}
+
+// flushComments prints the comments before the line.
+func (p *printer) flushComments(line int) {
+	for len(p.comments) > 0 && p.comments[0].Pos.Line < line {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.separate(c.Pos.Line)
+		p.writeLine(formatComment(c.Text))
+		p.lastLine, p.first = c.Pos.Line, false
+	}
+}
+
+// stmt prints the statement at the line with the comments before it.
+func (p *printer) stmt(line int, text string) {
+	p.flushComments(line)
+	p.separate(line)
+	p.writeLine(text)
+	p.lastLine, p.first = line, false
+}
+
+// open prints the statement opening a block at the line.
+func (p *printer) open(line int, text string) {
+	p.stmt(line, text+" {")
+	p.indent++
+	p.first = true
+}
+
+// close prints the end of the block at the line, with the comments in the block left.
+func (p *printer) close(line int) {
+	p.flushComments(line)
+	p.indent--
+	p.writeLine("}")
+	p.lastLine, p.first = line, false
+}
+
+// item is a statement or a block to print, at the line of the document.
+type item struct {
+	line  int
+	print func()
+}
+
+func (p *printer) printItems(items []item) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].line < items[j].line
+	})
+	for _, it := range items {
+		it.print()
+	}
+}
+
+// sortedStmt is a statement of a sorted group, e.g., a bind.
+type sortedStmt struct {
+	line int
+	kind string
+	key  string
+	text string
+}
+
+// printSortedGroup prints the group of the statements in the continuous lines sorted by the
+// keys, where the comments right before the statements are sorted together with them.
+func (p *printer) printSortedGroup(group []sortedStmt) {
+	start := group[0].line
+	for i := len(p.comments) - 1; i >= 0; i-- {
+		if line := p.comments[i].Pos.Line; line == start-1 {
+			start--
+		} else if line < start {
+			break
+		}
+	}
+	p.flushComments(start)
+	p.separate(start)
+
+	type attachedStmt struct {
+		sortedStmt
+		comments []Comment
+	}
+	stmts := make([]attachedStmt, 0, len(group))
+	for _, s := range group {
+		n := 0
+		for n < len(p.comments) && p.comments[n].Pos.Line < s.line {
+			n++
+		}
+		stmts = append(stmts, attachedStmt{sortedStmt: s, comments: p.comments[:n]})
+		p.comments = p.comments[n:]
+	}
+	sort.SliceStable(stmts, func(i, j int) bool {
+		return stmts[i].key < stmts[j].key
+	})
+	for _, s := range stmts {
+		for _, c := range s.comments {
+			p.writeLine(formatComment(c.Text))
+		}
+		p.writeLine(s.text)
+	}
+	p.lastLine, p.first = group[len(group)-1].line, false
+}
+
+// formatLabelRequirement formats the canonical label requirement back into the selector,
+// e.g., "tier in (a,b)" into "labels/tier in (a,b)", and "app" into "labels/app exists".
+func formatLabelRequirement(requirement string) string {
+	if strings.ContainsAny(requirement, " !=") {
+		return "labels/" + requirement
+	}
+	return "labels/" + requirement + " exists"
+}
+
+func (p *printer) printState(state *StateDeclaration) {
+	typ := state.Type
+	if state.IsArray {
+		typ = "[]" + typ
+	}
+	p.open(state.Pos.Line, state.Name+" "+typ)
+
+	var items []item
+	for key, pos := range state.SelectorPos {
+		text := key
+		if value := state.Selectors[key]; value != "" {
+			text += "=" + value
+		}
+		line := pos.Line
+		items = append(items, item{line: line, print: func() { p.stmt(line, text) }})
+	}
+	for i, requirement := range state.LabelRequirements {
+		line, text := state.LabelRequirementPos[i].Line, formatLabelRequirement(requirement)
+		items = append(items, item{line: line, print: func() { p.stmt(line, text) }})
+	}
+	p.printItems(items)
+
+	p.close(state.End.Line)
+}
+
+func formatAction(act *ActionDeclaration) string {
+	return act.Name + "(" + strings.Join(act.Params, ", ") + ")"
+}
+
+func (p *printer) printWorkflowStep(step *WorkflowStep) {
+	if step.Kind == WorkflowAction {
+		p.stmt(step.Pos.Line, step.Action+"()")
+		return
+	}
+
+	text := step.Kind
+	if step.Arg != "" {
+		text += " " + step.Arg
+	}
+	p.open(step.Pos.Line, text)
+	for i := range step.Steps {
+		p.printWorkflowStep(&step.Steps[i])
+	}
+	p.close(step.End.Line)
+}
+
+func (p *printer) printWorkflow(workflow *WorkflowDeclaration) {
+	p.open(workflow.Pos.Line, "workflow")
+
+	var items []item
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+		items = append(items, item{line: step.Pos.Line, print: func() { p.printWorkflowStep(step) }})
+	}
+	for i, name := range workflow.Deferred {
+		line, text := workflow.DeferredPos[i].Line, "defer "+name+"()"
+		items = append(items, item{line: line, print: func() { p.stmt(line, text) }})
+	}
+	p.printItems(items)
+
+	p.close(workflow.End.Line)
+}
+
+// inSpan tells if the position is in the span of the block.
+func inSpan(pos Pos, span Span) bool {
+	return pos.Line > span.Pos.Line && pos.Line < span.End.Line
+}
+
+func (p *printer) printDecl(decl *ControllerManagerDeclaration) {
+	p.open(decl.Pos.Line, fmt.Sprintf("decl %s for %s", decl.Name, decl.TargetType))
+
+	var items []item
+	if decl.ClusterScoped {
+		line := decl.ClusterScopedPos.Line
+		items = append(items, item{line: line, print: func() { p.stmt(line, "cluster-scoped") }})
+	}
+	if decl.Apply {
+		line, text := decl.ApplyPos.Line, "apply"
+		if decl.FieldManager != "" {
+			text += " " + decl.FieldManager
+		}
+		if decl.NoForce {
+			text += " noforce"
+		}
+		items = append(items, item{line: line, print: func() { p.stmt(line, text) }})
+	}
+	for _, span := range decl.StateBlocks {
+		span := span
+		items = append(items, item{line: span.Pos.Line, print: func() {
+			p.open(span.Pos.Line, "state")
+			states := lo.Filter(lo.Values(decl.States), func(state StateDeclaration, _ int) bool {
+				return inSpan(state.Pos, span)
+			})
+			sort.Slice(states, func(i, j int) bool {
+				return states[i].Pos.Line < states[j].Pos.Line
+			})
+			for i := range states {
+				p.printState(&states[i])
+			}
+			p.close(span.End.Line)
+		}})
+	}
+	for _, span := range decl.ActionBlocks {
+		span := span
+		items = append(items, item{line: span.Pos.Line, print: func() {
+			p.open(span.Pos.Line, "action")
+			for _, act := range decl.Actions {
+				if inSpan(act.Pos, span) {
+					p.stmt(act.Pos.Line, formatAction(&act))
+				}
+			}
+			p.close(span.End.Line)
+		}})
+	}
+	if decl.Finalizer != nil {
+		finalizer := decl.Finalizer
+		items = append(items, item{line: finalizer.Pos.Line, print: func() {
+			p.open(finalizer.Pos.Line, "finalizer "+finalizer.Name)
+			for _, name := range finalizer.Actions {
+				act := decl.ActionMap[name]
+				p.stmt(act.Pos.Line, formatAction(&act))
+			}
+			p.close(finalizer.End.Line)
+		}})
+	}
+	if decl.Workflow != nil {
+		items = append(items, item{line: decl.Workflow.Pos.Line, print: func() { p.printWorkflow(decl.Workflow) }})
+	}
+	p.printItems(items)
+
+	p.close(decl.End.Line)
+}
+
+// PrintDoc prints the parsed document canonically into w, with the comments put back. The
+// statements are indented by four spaces in the blocks, the continuous lines of the binds and
+// the aliases are sorted, and the selectors and the actions are printed in the normalized
+// forms.
+func PrintDoc(w io.Writer, doc *ControllerManagerDocument) error {
+	p := &printer{
+		buf:      &bytes.Buffer{},
+		comments: append([]Comment(nil), doc.Comments...),
+		first:    true,
+	}
+
+	var stmts []sortedStmt
+	for gv, bind := range doc.GvPkgBinds {
+		stmts = append(stmts, sortedStmt{line: bind.Pos.Line, kind: "bind", key: gv, text: fmt.Sprintf("bind %s %s", gv, bind.Pkg)})
+	}
+	for alias, gvk := range doc.GvkAliases {
+		stmts = append(stmts, sortedStmt{line: doc.AliasPos[alias].Line, kind: "alias", key: alias, text: fmt.Sprintf("alias %s %s", alias, gvk)})
+	}
+	sort.Slice(stmts, func(i, j int) bool {
+		return stmts[i].line < stmts[j].line
+	})
+
+	// The binds and the aliases are sorted in groups, which are the statements of the same
+	// kind with nothing but comments between them.
+	commentLines := make(map[int]bool, len(doc.Comments))
+	for _, c := range doc.Comments {
+		commentLines[c.Pos.Line] = true
+	}
+	onlyCommentsBetween := func(from, to int) bool {
+		for line := from + 1; line < to; line++ {
+			if !commentLines[line] {
+				return false
+			}
+		}
+		return true
+	}
+	var items []item
+	for len(stmts) > 0 {
+		n := 1
+		for n < len(stmts) && stmts[n].kind == stmts[0].kind && onlyCommentsBetween(stmts[n-1].line, stmts[n].line) {
+			n++
+		}
+		group := stmts[:n]
+		items = append(items, item{line: group[0].line, print: func() { p.printSortedGroup(group) }})
+		stmts = stmts[n:]
+	}
+	for _, decl := range doc.Decls {
+		decl := decl
+		items = append(items, item{line: decl.Pos.Line, print: func() { p.printDecl(&decl) }})
+	}
+	// The leading comments of the file, i.e., the continuous lines of comments before the
+	// first statement, stay at the top instead of being sorted together with the first bind
+	// or alias.
+	if len(items) > 0 && len(p.comments) > 0 {
+		first := lo.MinBy(items, func(a, b item) bool { return a.line < b.line })
+		end := p.comments[0].Pos.Line
+		for _, c := range p.comments {
+			if c.Pos.Line != end || c.Pos.Line >= first.line {
+				break
+			}
+			end++
+		}
+		p.flushComments(end)
+	}
+	p.printItems(items)
+	p.flushComments(int(^uint(0) >> 1))
+
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// FormatDoc parses the document and prints it back canonically. The document must be valid,
+// and the formatted one is verified to parse into the same document.
+func FormatDoc(src []byte, opts ParseOptions) ([]byte, error) {
+	doc, err := ParseDocWithOptions(bytes.NewReader(src), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := PrintDoc(buf, doc); err != nil {
+		return nil, err
+	}
+
+	formatted, err := ParseDocWithOptions(bytes.NewReader(buf.Bytes()), ParseOptions{FileName: opts.FileName})
+	if err != nil {
+		return nil, fmt.Errorf("format error: %w", err)
+	}
+	if !equalDocs(doc, formatted) {
+		return nil, errors.New("format error: formatted document differs")
+	}
+	return buf.Bytes(), nil
+}
+
+// equalDocs tells if the documents are the same except the positions, and have the same
+// comments.
+func equalDocs(a, b *ControllerManagerDocument) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil || !bytes.Equal(ja, jb) {
+		return false
+	}
+	commentTexts := func(doc *ControllerManagerDocument) []string {
+		texts := lo.Map(doc.Comments, func(c Comment, _ int) string {
+			return c.Text
+		})
+		sort.Strings(texts)
+		return texts
+	}
+	return reflect.DeepEqual(commentTexts(a), commentTexts(b))
+}
@@ -0,0 +1,186 @@
+package gen
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func Test_FormatDoc(t *testing.T) {
+	const src = `// Copyright header.
+
+   bind v1   k8s.io/api/core/v1
+// Batch.
+bind batch/v1 k8s.io/api/batch/v1
+bind apps/v1 k8s.io/api/apps/v1
+
+alias Pod   v1/Pod
+  alias Job batch/v1/Job
+alias Deployment apps/v1/Deployment
+//Manager of jobs.
+decl JobManager for Job {
+
+  apply example.io/manager   noforce
+	state {
+      // Pods of the job.
+      pods []Pod {
+          labels/job=${target.Name}
+          labels/tier   in   (a,b)
+          // Not legacy ones.
+          labels/!legacy
+          labels/app    exists
+          owned
+      }
+
+
+      deploy Deployment {
+          name=${target.Name}
+          // Trailing in state.
+      }
+	}
+
+	action {
+	  // Sync the pods.
+	  Sync( pods,deploy )
+	  Run()
+	}
+	finalizer example.io/cleanup {
+	  Forget(pods)
+	}
+	workflow {
+	  Run()
+	  unless   Suspended {
+	      // Timeout.
+	      timeout 1m {
+	          Sync()
+	      }
+	  }
+	  defer  Forget()
+	}
+}
+
+
+
+// Trailing comment.
+`
+
+	const expected = `// Copyright header.
+
+bind apps/v1 k8s.io/api/apps/v1
+// Batch.
+bind batch/v1 k8s.io/api/batch/v1
+bind v1 k8s.io/api/core/v1
+
+alias Deployment apps/v1/Deployment
+alias Job batch/v1/Job
+alias Pod v1/Pod
+// Manager of jobs.
+decl JobManager for Job {
+    apply example.io/manager noforce
+    state {
+        // Pods of the job.
+        pods []Pod {
+            labels/job=${target.Name}
+            labels/tier in (a,b)
+            // Not legacy ones.
+            labels/!legacy
+            labels/app exists
+            owned
+        }
+
+        deploy Deployment {
+            name=${target.Name}
+            // Trailing in state.
+        }
+    }
+
+    action {
+        // Sync the pods.
+        Sync(pods, deploy)
+        Run()
+    }
+    finalizer example.io/cleanup {
+        Forget(pods)
+    }
+    workflow {
+        Run()
+        unless Suspended {
+            // Timeout.
+            timeout 1m {
+                Sync()
+            }
+        }
+        defer Forget()
+    }
+}
+
+// Trailing comment.
+`
+
+	formatted, err := FormatDoc([]byte(src), ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(formatted) != expected {
+		t.Fatalf("unexpected formatted document:\n%s", formatted)
+	}
+
+	// Formatting is idempotent.
+	formatted, err = FormatDoc(formatted, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(formatted) != expected {
+		t.Fatalf("formatting is not idempotent:\n%s", formatted)
+	}
+}
+
+func Test_FormatDoc_FileComments(t *testing.T) {
+	testcases := map[string]struct {
+		src      string
+		expected string
+	}{
+		"attached": {
+			src:      "// Header.\nbind v1 k8s.io/api/core/v1\nbind batch/v1 k8s.io/api/batch/v1\n",
+			expected: "// Header.\nbind batch/v1 k8s.io/api/batch/v1\nbind v1 k8s.io/api/core/v1\n",
+		},
+		"separated": {
+			src:      "// Header.\n\n// Core.\nbind v1 k8s.io/api/core/v1\n// Batch.\nbind batch/v1 k8s.io/api/batch/v1\n",
+			expected: "// Header.\n\n// Batch.\nbind batch/v1 k8s.io/api/batch/v1\n// Core.\nbind v1 k8s.io/api/core/v1\n",
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			formatted, err := FormatDoc([]byte(tc.src), ParseOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(formatted) != tc.expected {
+				t.Fatalf("unexpected formatted document:\n%s", formatted)
+			}
+		})
+	}
+}
+
+func Test_FormatDoc_Example(t *testing.T) {
+	src, err := os.ReadFile("../../example/cronjob.cm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := FormatDoc(src, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := FormatDoc(formatted, ParseOptions{}); err != nil || string(again) != string(formatted) {
+		t.Fatalf("formatting is not idempotent: %v\n%s", err, again)
+	}
+}
+
+func Test_FormatDoc_Invalid(t *testing.T) {
+	_, err := FormatDoc([]byte(testDocHeader+"decl JobManager for Job {\n"), ParseOptions{})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Code != CodeUnclosedBlock {
+		t.Fatalf("expect unclosed block error, but got %v", err)
+	}
+}
@@ -0,0 +1,370 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/arkbriar/ctrlkit/ctrlkit/pkg/gen"
+)
+
+// document is an opened .cm document and the results of parsing it, which are partial if
+// there are errors.
+type document struct {
+	uri   string
+	lines []string
+	doc   *gen.ControllerManagerDocument
+	err   error
+}
+
+func newDocument(uri, text string) *document {
+	doc, err := gen.ParseDocWithOptions(strings.NewReader(text), gen.ParseOptions{
+		FileName:  uri,
+		AllErrors: true,
+	})
+	if doc == nil {
+		doc = &gen.ControllerManagerDocument{}
+	}
+	return &document{
+		uri:   uri,
+		lines: strings.Split(text, "\n"),
+		doc:   doc,
+		err:   err,
+	}
+}
+
+func (d *document) line(n int) string {
+	if n < 0 || n >= len(d.lines) {
+		return ""
+	}
+	return strings.TrimSuffix(d.lines[n], "\r")
+}
+
+// offset returns the byte offset of the position in the line, where the characters of the
+// position are UTF-16 code units. It returns -1 if the position is out of the line.
+func (d *document) offset(pos Position) int {
+	line := d.line(pos.Line)
+	if pos.Character < 0 {
+		return -1
+	}
+	units := 0
+	for i, r := range line {
+		if units >= pos.Character {
+			return i
+		}
+		units += utf16Len(r)
+	}
+	if units >= pos.Character {
+		return len(line)
+	}
+	return -1
+}
+
+// position returns the position of the byte offset in the line, in UTF-16 code units.
+func (d *document) position(line, offset int) Position {
+	text := d.line(line)
+	if offset < 0 {
+		offset = 0
+	} else if offset > len(text) {
+		offset = len(text)
+	}
+	units := 0
+	for _, r := range text[:offset] {
+		units += utf16Len(r)
+	}
+	return Position{Line: line, Character: units}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// rangeOf returns the range of the token at the one-based position of the parser, whose
+// columns are counted in bytes.
+func (d *document) rangeOf(line, column int, token string) Range {
+	start := column - 1
+	end := len(d.line(line - 1))
+	if token != "" {
+		end = start + len(token)
+	}
+	return Range{Start: d.position(line-1, start), End: d.position(line-1, end)}
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	if d.err == nil {
+		return diagnostics
+	}
+
+	var errs gen.ParseErrors
+	if !errors.As(d.err, &errs) {
+		return append(diagnostics, Diagnostic{
+			Severity: SeverityError,
+			Source:   "ctrlkit",
+			Message:  d.err.Error(),
+		})
+	}
+	for _, e := range errs {
+		msg := e.Msg
+		if e.Err != nil {
+			msg += ": " + e.Err.Error()
+		}
+		if e.Hint != "" {
+			msg += "\nhint: " + e.Hint
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.rangeOf(e.Line, e.Column, e.Token),
+			Severity: SeverityError,
+			Code:     string(e.Code),
+			Source:   "ctrlkit",
+			Message:  msg,
+		})
+	}
+	return diagnostics
+}
+
+// scope is where a line is in the document, i.e., the enclosing decl and blocks.
+type scope struct {
+	// decl is the name of the enclosing decl, if any.
+	decl string
+	// blocks are the first words of the lines opening the enclosing blocks, the outermost
+	// first, e.g., ["decl", "state", "pods"] for the selectors of state "pods".
+	blocks []string
+}
+
+func (s *scope) top() string {
+	if len(s.blocks) == 0 {
+		return ""
+	}
+	return s.blocks[len(s.blocks)-1]
+}
+
+// scopeAt returns the scope of the line by tracking the blocks opened and closed before it.
+func (d *document) scopeAt(line int) scope {
+	var s scope
+	for i := 0; i < line && i < len(d.lines); i++ {
+		words := strings.Fields(d.line(i))
+		if len(words) == 0 || strings.HasPrefix(words[0], "//") {
+			continue
+		}
+		if len(words) == 1 && words[0] == "}" {
+			if len(s.blocks) > 0 {
+				s.blocks = s.blocks[:len(s.blocks)-1]
+			}
+			if len(s.blocks) == 0 {
+				s.decl = ""
+			}
+		} else if words[len(words)-1] == "{" {
+			if len(s.blocks) == 0 && words[0] == "decl" && len(words) > 1 {
+				s.decl = words[1]
+			}
+			s.blocks = append(s.blocks, words[0])
+		}
+	}
+	return s
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// wordAt returns the word of letters, digits and "_" at the position, and its range.
+func (d *document) wordAt(pos Position) (string, Range, bool) {
+	line := d.line(pos.Line)
+	offset := d.offset(pos)
+	if offset < 0 {
+		return "", Range{}, false
+	}
+	start, end := offset, offset
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isWordChar(line[end]) {
+		end++
+	}
+	if start == end {
+		return "", Range{}, false
+	}
+	return line[start:end], Range{Start: d.position(pos.Line, start), End: d.position(pos.Line, end)}, true
+}
+
+// fieldIndex returns the index of the field at the offset of the line, where "[]" before
+// the type of a state doesn't count.
+func fieldIndex(line string, offset int) int {
+	return len(strings.Fields(strings.TrimSuffix(line[:offset], "[]")))
+}
+
+// inParens tells if the offset of the line is in the parentheses, e.g., the params of an
+// action.
+func inParens(line string, offset int) bool {
+	prefix := line[:offset]
+	return strings.Count(prefix, "(") > strings.Count(prefix, ")")
+}
+
+func isActionBlock(block string) bool {
+	return block == "action" || block == "finalizer"
+}
+
+func (d *document) decl(s *scope) (gen.ControllerManagerDeclaration, bool) {
+	decl, ok := d.doc.Decls[s.decl]
+	return decl, ok
+}
+
+func (d *document) aliasDetail(alias string) string {
+	goType, _, err := gen.ResolveGoType(d.doc, alias)
+	if err != nil {
+		return d.doc.GetGvkByAlias(alias)
+	}
+	return goType
+}
+
+func (d *document) stateGoType(state *gen.StateDeclaration) string {
+	goType := "*" + d.aliasDetail(state.Type)
+	if state.IsArray {
+		goType = "[]" + goType[1:]
+	}
+	return goType
+}
+
+func (d *document) completion(pos Position) []CompletionItem {
+	items := []CompletionItem{}
+	line := d.line(pos.Line)
+	offset := d.offset(pos)
+	if offset < 0 {
+		return items
+	}
+	prefix := line[:offset]
+	fields := strings.Fields(prefix)
+	// Index of the field being typed.
+	index := len(fields)
+	if len(fields) > 0 && !strings.HasSuffix(prefix, " ") && !strings.HasSuffix(prefix, "\t") {
+		index--
+	}
+
+	s := d.scopeAt(pos.Line)
+	switch {
+	case s.top() == "state" && index == 1:
+		aliases := lo.Keys(d.doc.GvkAliases)
+		sort.Strings(aliases)
+		for _, alias := range aliases {
+			items = append(items, CompletionItem{
+				Label:  alias,
+				Kind:   CompletionItemKindClass,
+				Detail: d.aliasDetail(alias),
+			})
+		}
+	case isActionBlock(s.top()) && inParens(line, offset):
+		decl, ok := d.decl(&s)
+		if !ok {
+			break
+		}
+		stateNames := lo.Keys(decl.States)
+		sort.Strings(stateNames)
+		for _, name := range stateNames {
+			state := decl.States[name]
+			items = append(items, CompletionItem{
+				Label:  name,
+				Kind:   CompletionItemKindVariable,
+				Detail: d.stateGoType(&state),
+			})
+		}
+	case len(s.blocks) == 0 && len(fields) > 0 && fields[0] == "alias" && index == 2:
+		gvs := lo.Keys(d.doc.GvPkgBinds)
+		sort.Strings(gvs)
+		for _, gv := range gvs {
+			items = append(items, CompletionItem{
+				Label:  gv + "/",
+				Kind:   CompletionItemKindModule,
+				Detail: d.doc.GetGvPkg(gv),
+			})
+		}
+	}
+	return items
+}
+
+// symbol is an alias or a state referenced at a position.
+type symbol struct {
+	alias string
+	state *gen.StateDeclaration
+	rng   Range
+}
+
+// symbolAt resolves the word at the position into an alias or a state of the enclosing decl.
+// The names and the types in the state block, and the params of the actions are resolved by
+// where they are, and the others are aliases if declared.
+func (d *document) symbolAt(pos Position) (symbol, bool) {
+	word, rng, ok := d.wordAt(pos)
+	if !ok {
+		return symbol{}, false
+	}
+	line := d.line(pos.Line)
+	s := d.scopeAt(pos.Line)
+	decl, inDecl := d.decl(&s)
+
+	start := d.offset(rng.Start)
+	stateSlot := s.top() == "state" && fieldIndex(line, start) == 0 ||
+		isActionBlock(s.top()) && inParens(line, start)
+	if stateSlot {
+		if state, ok := decl.States[word]; inDecl && ok {
+			return symbol{state: &state, rng: rng}, true
+		}
+		return symbol{}, false
+	}
+	if d.doc.DoesAliasExists(word) {
+		return symbol{alias: word, rng: rng}, true
+	}
+	if state, ok := decl.States[word]; inDecl && ok {
+		return symbol{state: &state, rng: rng}, true
+	}
+	return symbol{}, false
+}
+
+func (d *document) hover(pos Position) *Hover {
+	sym, ok := d.symbolAt(pos)
+	if !ok {
+		return nil
+	}
+
+	var value string
+	if sym.state != nil {
+		value = fmt.Sprintf("```go\n%s %s\n```", sym.state.Name, d.stateGoType(sym.state))
+		if len(sym.state.Comments) > 0 {
+			value += "\n" + strings.Join(sym.state.Comments, "\n")
+		}
+	} else {
+		goType, pkg, err := gen.ResolveGoType(d.doc, sym.alias)
+		if err != nil {
+			return nil
+		}
+		value = fmt.Sprintf("```go\n%s\n```\nalias of `%s` in package `%s`", goType, d.doc.GetGvkByAlias(sym.alias), pkg)
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: value},
+		Range:    &sym.rng,
+	}
+}
+
+func (d *document) definition(pos Position) *Location {
+	sym, ok := d.symbolAt(pos)
+	if !ok {
+		return nil
+	}
+
+	var at gen.Pos
+	var name string
+	if sym.state != nil {
+		at, name = sym.state.Pos, sym.state.Name
+	} else {
+		at, name = d.doc.AliasPos[sym.alias], sym.alias
+	}
+	if at.Line == 0 {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.rangeOf(at.Line, at.Column, name)}
+}
@@ -0,0 +1,178 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ErrExitWithoutShutdown is returned by Serve if the client asks to exit without
+// shutting down the server first.
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+// Server is a language server of the .cm documents, which serves the requests sequentially
+// over a stream, e.g., the stdio. It supports the diagnostics, the completion, the hover and
+// the definition of the documents synced in full.
+type Server struct {
+	r *bufio.Reader
+	w io.Writer
+
+	docs     map[string]*document
+	shutdown bool
+}
+
+// NewServer creates a server reading the requests from r and writing the responses to w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		r:    bufio.NewReader(r),
+		w:    w,
+		docs: make(map[string]*document),
+	}
+}
+
+// Serve serves until the client exits or the stream ends.
+func (s *Server) Serve() error {
+	for {
+		content, err := readMessage(s.r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			if err := s.replyError(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}) error {
+	return writeMessage(s.w, response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) replyError(id *json.RawMessage, code int, msg string) error {
+	return writeMessage(s.w, errorResponse{JSONRPC: "2.0", ID: id, Error: responseError{Code: code, Message: msg}})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return writeMessage(s.w, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) publishDiagnostics(uri string) error {
+	diagnostics := []Diagnostic{}
+	if doc, ok := s.docs[uri]; ok {
+		diagnostics = doc.diagnostics()
+	}
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+// handle handles the request, or the notification which has no ID. Only the errors of
+// writing are returned, and the others are replied.
+func (s *Server) handle(req *request) error {
+	isNotification := req.ID == nil
+	if s.shutdown {
+		if isNotification {
+			return nil
+		}
+		return s.replyError(req.ID, codeInvalidRequest, "server is shut down")
+	}
+
+	// unmarshal decodes the params, or replies the error.
+	unmarshal := func(v interface{}) (bool, error) {
+		if err := json.Unmarshal(req.Params, v); err != nil {
+			if isNotification {
+				return false, nil
+			}
+			return false, s.replyError(req.ID, codeInvalidParams, err.Error())
+		}
+		return true, nil
+	}
+
+	switch req.Method {
+	case "initialize":
+		return s.reply(req.ID, InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:   TextDocumentSyncKindFull,
+				CompletionProvider: &CompletionOptions{TriggerCharacters: []string{" ", "(", ","}},
+				HoverProvider:      true,
+				DefinitionProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "ctrlkit-gen"},
+		})
+	case "shutdown":
+		s.shutdown = true
+		return s.reply(req.ID, nil)
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if ok, err := unmarshal(&params); !ok {
+			return err
+		}
+		s.docs[params.TextDocument.URI] = newDocument(params.TextDocument.URI, params.TextDocument.Text)
+		return s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if ok, err := unmarshal(&params); !ok {
+			return err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil
+		}
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		s.docs[params.TextDocument.URI] = newDocument(params.TextDocument.URI, text)
+		return s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if ok, err := unmarshal(&params); !ok {
+			return err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/completion", "textDocument/hover", "textDocument/definition":
+		var params TextDocumentPositionParams
+		if ok, err := unmarshal(&params); !ok {
+			return err
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return s.replyError(req.ID, codeInvalidParams, "document not opened: "+params.TextDocument.URI)
+		}
+		switch req.Method {
+		case "textDocument/completion":
+			return s.reply(req.ID, CompletionList{Items: doc.completion(params.Position)})
+		case "textDocument/hover":
+			if hover := doc.hover(params.Position); hover != nil {
+				return s.reply(req.ID, hover)
+			}
+		default:
+			if location := doc.definition(params.Position); location != nil {
+				return s.reply(req.ID, location)
+			}
+		}
+		return s.reply(req.ID, nil)
+	default:
+		if isNotification {
+			return nil
+		}
+		return s.replyError(req.ID, codeMethodNotFound, "method not found: "+req.Method)
+	}
+}
@@ -0,0 +1,246 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///cronjob.cm"
+
+const testDoc = `bind v1 k8s.io/api/core/v1
+bind batch/v1 k8s.io/api/batch/v1
+
+alias Job batch/v1/Job
+alias Pod v1/Pod
+
+decl JobManager for Job {
+    state {
+        // Pods of the job.
+        pods []Pod {
+            labels/job=${target.Name}
+        }
+    }
+
+    action {
+        Sync(pods)
+        Run(nodes)
+    }
+}
+`
+
+// session is a scripted session of the requests and the notifications, which is served at
+// once and then replied in order.
+type session struct {
+	t   *testing.T
+	in  bytes.Buffer
+	ids int
+}
+
+func (s *session) send(id int, method string, params interface{}) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if id > 0 {
+		msg["id"] = id
+	}
+	if params != nil {
+		msg["params"] = params
+	}
+	if err := writeMessage(&s.in, msg); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func (s *session) request(method string, params interface{}) {
+	s.ids++
+	s.send(s.ids, method, params)
+}
+
+func (s *session) notify(method string, params interface{}) {
+	s.send(0, method, params)
+}
+
+func (s *session) at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+// serve serves the session and returns the messages replied.
+func (s *session) serve() []map[string]json.RawMessage {
+	out := &bytes.Buffer{}
+	if err := NewServer(&s.in, out).Serve(); err != nil {
+		s.t.Fatal(err)
+	}
+
+	var msgs []map[string]json.RawMessage
+	r := bufio.NewReader(out)
+	for r.Buffered() > 0 || out.Len() > 0 {
+		content, err := readMessage(r)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(content, &msg); err != nil {
+			s.t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func decode(t *testing.T, raw json.RawMessage, v interface{}) {
+	if err := json.Unmarshal(raw, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Server(t *testing.T) {
+	s := &session{t: t}
+	s.request("initialize", map[string]interface{}{})
+	s.notify("initialized", map[string]interface{}{})
+	s.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, LanguageID: "ctrlkit", Version: 1, Text: testDoc},
+	})
+	// Completion of the state type, the action params and the group version of alias.
+	s.request("textDocument/completion", s.at(9, 14))
+	s.request("textDocument/completion", s.at(15, 13))
+	s.request("textDocument/completion", s.at(4, 10))
+	// Hover on the state type and the action param.
+	s.request("textDocument/hover", s.at(9, 16))
+	s.request("textDocument/hover", s.at(15, 15))
+	// Definition of the target type and the action param.
+	s.request("textDocument/definition", s.at(6, 20))
+	s.request("textDocument/definition", s.at(15, 14))
+	s.request("textDocument/definition", s.at(15, 8))
+	s.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: testURI},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: strings.Replace(testDoc, "Run(nodes)", "Run(pods)", 1)}},
+	})
+	s.request("textDocument/unknown", s.at(0, 0))
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	msgs := s.serve()
+	if len(msgs) != 13 {
+		t.Fatalf("expect 13 messages, but got %d", len(msgs))
+	}
+
+	var initResult InitializeResult
+	decode(t, msgs[0]["result"], &initResult)
+	if !initResult.Capabilities.HoverProvider || !initResult.Capabilities.DefinitionProvider || initResult.Capabilities.CompletionProvider == nil {
+		t.Fatalf("unexpected capabilities: %+v", initResult.Capabilities)
+	}
+
+	var diagnostics PublishDiagnosticsParams
+	decode(t, msgs[1]["params"], &diagnostics)
+	if len(diagnostics.Diagnostics) != 1 {
+		t.Fatalf("expect 1 diagnostic, but got %+v", diagnostics.Diagnostics)
+	}
+	if d := diagnostics.Diagnostics[0]; d.Code != "state-not-found" ||
+		d.Range != (Range{Start: Position{Line: 16, Character: 12}, End: Position{Line: 16, Character: 17}}) {
+		t.Fatalf("unexpected diagnostic: %+v", d)
+	}
+
+	for i, expected := range [][]string{
+		{"Job", "Pod"},
+		{"pods"},
+		{"batch/v1/", "v1/"},
+	} {
+		var list CompletionList
+		decode(t, msgs[2+i]["result"], &list)
+		labels := make([]string, 0, len(list.Items))
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+		}
+		if !reflect.DeepEqual(labels, expected) {
+			t.Fatalf("completion %d should be %v, but got %v", i, expected, labels)
+		}
+	}
+
+	for i, expected := range []string{
+		"```go\ncorev1.Pod\n```\nalias of `v1/Pod` in package `k8s.io/api/core/v1`",
+		"```go\npods []corev1.Pod\n```\nPods of the job.",
+	} {
+		var hover Hover
+		decode(t, msgs[5+i]["result"], &hover)
+		if hover.Contents.Value != expected {
+			t.Fatalf("hover %d should be %q, but got %q", i, expected, hover.Contents.Value)
+		}
+	}
+
+	for i, expected := range []string{
+		`{"uri":"file:///cronjob.cm","range":{"start":{"line":3,"character":6},"end":{"line":3,"character":9}}}`,
+		`{"uri":"file:///cronjob.cm","range":{"start":{"line":9,"character":8},"end":{"line":9,"character":12}}}`,
+		`null`,
+	} {
+		if result := string(msgs[7+i]["result"]); result != expected {
+			t.Fatalf("definition %d should be %s, but got %s", i, expected, result)
+		}
+	}
+
+	decode(t, msgs[10]["params"], &diagnostics)
+	if len(diagnostics.Diagnostics) != 0 {
+		t.Fatalf("diagnostics should be cleared, but got %+v", diagnostics.Diagnostics)
+	}
+
+	if string(msgs[11]["error"]) != fmt.Sprintf(`{"code":%d,"message":"method not found: textDocument/unknown"}`, codeMethodNotFound) {
+		t.Fatalf("unexpected error: %s", msgs[11]["error"])
+	}
+	if string(msgs[12]["result"]) != "null" {
+		t.Fatalf("unexpected result of shutdown: %s", msgs[12]["result"])
+	}
+}
+
+func Test_Server_ExitWithoutShutdown(t *testing.T) {
+	s := &session{t: t}
+	s.notify("exit", nil)
+	if err := NewServer(&s.in, &bytes.Buffer{}).Serve(); err != ErrExitWithoutShutdown {
+		t.Fatalf("expect ErrExitWithoutShutdown, but got %v", err)
+	}
+}
+
+func Test_Server_UTF16(t *testing.T) {
+	// "é" is one UTF-16 code unit in two bytes, and "🚀" is two in four bytes.
+	text := strings.Replace(testDoc, "Run(nodes)", "Run(é🚀, pods)", 1)
+
+	s := &session{t: t}
+	s.request("initialize", map[string]interface{}{})
+	s.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, LanguageID: "ctrlkit", Version: 1, Text: text},
+	})
+	s.request("textDocument/hover", s.at(16, 19))
+	s.request("textDocument/completion", s.at(16, 16))
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	msgs := s.serve()
+	if len(msgs) != 5 {
+		t.Fatalf("expect 5 messages, but got %d", len(msgs))
+	}
+
+	var diagnostics PublishDiagnosticsParams
+	decode(t, msgs[1]["params"], &diagnostics)
+	if len(diagnostics.Diagnostics) != 1 {
+		t.Fatalf("expect 1 diagnostic, but got %+v", diagnostics.Diagnostics)
+	}
+	if d := diagnostics.Diagnostics[0]; d.Range != (Range{Start: Position{Line: 16, Character: 12}, End: Position{Line: 16, Character: 15}}) {
+		t.Fatalf("unexpected diagnostic: %+v", d)
+	}
+
+	var hover Hover
+	decode(t, msgs[2]["result"], &hover)
+	if hover.Range == nil || *hover.Range != (Range{Start: Position{Line: 16, Character: 17}, End: Position{Line: 16, Character: 21}}) {
+		t.Fatalf("unexpected hover: %+v", hover)
+	}
+
+	var list CompletionList
+	decode(t, msgs[3]["result"], &list)
+	if len(list.Items) != 1 || list.Items[0].Label != "pods" {
+		t.Fatalf("unexpected completion: %+v", list.Items)
+	}
+}
@@ -6,9 +6,16 @@ import (
 
 type CronJobSpec struct {
 	Suspend *bool `json:"suspend,omitempty"`
+
+	// The number of successful finished jobs to retain. Defaults to 3.
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+
+	// The number of failed finished jobs to retain. Defaults to 1.
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
 }
 
 type CronJobStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
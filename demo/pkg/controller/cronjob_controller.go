@@ -33,28 +33,19 @@ func (c *CronJobController) Reconcile(ctx context.Context, request reconcile.Req
 		return ctrlkit.RequeueIfError(err)
 	}
 
-	// Build state and impl for controller manager.
-	state := manager.NewCronJobControllerManagerState(c.Client, cronJob.DeepCopy())
-	impl := manager.NewCronJobControllerManagerImpl(c.Client, cronJob.DeepCopy())
-	mgr := manager.NewCronJobControllerManager(state, impl, logger)
-
-	// Always update the status after actions have run.
-	defer mgr.UpdateCronJobStatus().Run(ctx)
-
-	// Assemble the actions and run.
-	return ctrlkit.IgnoreExit(
-		// Run these actions and doesn't care the order, and join the results.
-		ctrlkit.Join(
-			// Update the status of CronJob as always.
-			mgr.ListActiveJobsAndUpdateStatus(),
-			// Clean the old completed/failed jobs accroding to the limits.
-			mgr.CleanUpOldJobsExceedsHistoryLimits(),
-			// Try to run the next scheduled job when not suspended, otherwise do nothing.
-			ctrlkit.If(cronJob.Spec.Suspend == nil || *cronJob.Spec.Suspend, mgr.RunNextScheduledJob()),
-		).Run(ctx),
-	)
+	// Build state and impl for controller manager. The conditions of the CronJob are
+	// managed according to the outcomes of the actions. They share the same target, so
+	// that the status is updated onto the object patched with the finalizer.
+	target := &cronJob
+	conditions := ctrlkit.NewConditionManager(c.Client, target, &target.Status.Conditions)
+	state := manager.NewCronJobControllerManagerState(c.Client, target)
+	impl := manager.NewCronJobControllerManagerImpl(c.Client, target, conditions)
+	mgr := manager.NewCronJobControllerManager(state, impl, logger, manager.CronJobControllerManager_WithActionHook(conditions))
+
+	// Run the workflow declared, which updates the status after the other actions have run.
+	return ctrlkit.IgnoreExit(mgr.Workflow().Run(ctx))
 }
 
 func (c *CronJobController) SetupWithManager(mgr ctrl.Manager) error {
-	return nil
+	return manager.SetupCronJobControllerManagerWithManager(mgr, c)
 }
@@ -4,16 +4,24 @@ import (
 	"context"
 	"testing"
 
+	"github.com/arkbriar/ctrlkit/pkg/ctrlkit"
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	apiv1 "demo/api/v1"
+	"demo/pkg/manager"
 )
 
 var scheme = runtime.NewScheme()
@@ -23,9 +31,22 @@ func init() {
 	_ = apiv1.AddToScheme(scheme)
 }
 
+// countingClient counts the gets of CronJob.
+type countingClient struct {
+	client.Client
+	gets int
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if _, ok := obj.(*apiv1.CronJob); ok {
+		c.gets++
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
 func Test_CronJobController_Reconcile(t *testing.T) {
-	controller := &CronJobController{
-		Client: fake.NewClientBuilder().
+	c := &countingClient{
+		Client: manager.WithCronJobControllerManagerIndexes(fake.NewClientBuilder().
 			WithScheme(scheme).
 			WithObjects(&apiv1.CronJob{
 				ObjectMeta: metav1.ObjectMeta{
@@ -33,7 +54,10 @@ func Test_CronJobController_Reconcile(t *testing.T) {
 					Namespace: "default",
 				},
 			}).
-			Build(),
+			Build()),
+	}
+	controller := &CronJobController{
+		Client: c,
 		Logger: zapr.NewLogger(zap.NewExample()),
 	}
 
@@ -47,4 +71,47 @@ func Test_CronJobController_Reconcile(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	// The status is updated onto the object patched with the finalizer without conflicts.
+	if c.gets != 1 {
+		t.Fatalf("cronjob should be got only once: %d", c.gets)
+	}
+
+	var cronJob apiv1.CronJob
+	if err := controller.Client.Get(context.Background(), types.NamespacedName{
+		Name:      "example",
+		Namespace: "default",
+	}, &cronJob); err != nil {
+		t.Fatal(err)
+	}
+	if !controllerutil.ContainsFinalizer(&cronJob, manager.CronJobFinalizer) {
+		t.Fatal("finalizer is not added")
+	}
+	if !meta.IsStatusConditionTrue(cronJob.Status.Conditions, ctrlkit.ConditionReady) {
+		t.Fatal("cronjob is not ready")
+	}
+}
+
+func Test_CronJobController_SetupWithManager(t *testing.T) {
+	mgr, err := ctrl.NewManager(&rest.Config{Host: "http://127.0.0.1:0"}, ctrl.Options{
+		Scheme:             scheme,
+		MetricsBindAddress: "0",
+		MapperProvider: func(c *rest.Config) (meta.RESTMapper, error) {
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(apiv1.GroupVersion.WithKind("CronJob"), meta.RESTScopeNamespace)
+			mapper.Add(batchv1.SchemeGroupVersion.WithKind("Job"), meta.RESTScopeNamespace)
+			return mapper, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controller := &CronJobController{
+		Client: mgr.GetClient(),
+		Logger: zapr.NewLogger(zap.NewExample()),
+	}
+	if err := controller.SetupWithManager(mgr); err != nil {
+		t.Fatal(err)
+	}
 }
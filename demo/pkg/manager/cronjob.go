@@ -13,34 +13,37 @@ import (
 	"github.com/arkbriar/ctrlkit/pkg/ctrlkit"
 	"github.com/go-logr/logr"
 	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // CronJobControllerManagerState is the state manager of CronJobControllerManager.
 type CronJobControllerManagerState struct {
-	client.Reader
+	client.Client
 	target *apiv1.CronJob
 }
 
 // GetJobs lists jobs with the following selectors:
-//   + fields/.metadata.controller=${target.Name}
-//   + labels/cronjob=${target.Name}
-//   + owned
+//   - fields/.metadata.controller=${target.Name}
+//   - labels/cronjob=${target.Name}
+//   - owned
+//   - prune
 func (s *CronJobControllerManagerState) GetJobs(ctx context.Context) ([]batchv1.Job, error) {
 	var jobsList batchv1.JobList
 
 	matchingLabels := map[string]string{
 		"cronjob": s.target.Name,
 	}
-	matchingFields := map[string]string{
-		".metadata.controller": s.target.Name,
-	}
 
 	err := s.List(ctx, &jobsList, client.InNamespace(s.target.Namespace),
-		client.MatchingLabels(matchingLabels), client.MatchingFields(matchingFields))
+		client.MatchingLabels(matchingLabels),
+		client.MatchingFields{
+			".metadata.controller": s.target.Name,
+		})
 	if err != nil {
-		return nil, fmt.Errorf("unable to get state 'jobs': more than 1 object found")
+		return nil, fmt.Errorf("unable to get state 'jobs': %w", err)
 	}
 
 	var validated []batchv1.Job
@@ -54,107 +57,288 @@ func (s *CronJobControllerManagerState) GetJobs(ctx context.Context) ([]batchv1.
 }
 
 // NewCronJobControllerManagerState returns a CronJobControllerManagerState (target is not copied).
-func NewCronJobControllerManagerState(reader client.Reader, target *apiv1.CronJob) CronJobControllerManagerState {
+func NewCronJobControllerManagerState(c client.Client, target *apiv1.CronJob) CronJobControllerManagerState {
 	return CronJobControllerManagerState{
-		Reader: reader,
+		Client: c,
 		target: target,
 	}
 }
 
 // CronJobControllerManagerImpl declares the implementation interface for CronJobControllerManager.
 type CronJobControllerManagerImpl interface {
-	ctrlkit.CrontollerManagerActionLifeCycleHook
-
 	// List all active jobs, and update the status.
 	ListActiveJobsAndUpdateStatus(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error)
 
-	// Clean up old jobs according to the history limit.
-	CleanUpOldJobsExceedsHistoryLimits(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error)
-
 	// Run the next job if it's on time, or otherwise we should wait
 	// until the next scheduled time.
 	RunNextScheduledJob(ctx context.Context, logger logr.Logger) (ctrl.Result, error)
 
 	// Update status of CronJob.
 	UpdateCronJobStatus(ctx context.Context, logger logr.Logger) (ctrl.Result, error)
+
+	// Delete all the jobs of the CronJob.
+	DeleteAllJobs(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error)
+
+	// DesiredJobs returns the names of the desired objects of state "jobs", others are pruned.
+	DesiredJobs(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) ([]string, error)
+
+	// Suspended is the predicate "Suspended" of the workflow.
+	Suspended(ctx context.Context, logger logr.Logger) (bool, error)
 }
 
+// Pre-defined actions in CronJobControllerManager.
+const (
+	CronJobAction_ListActiveJobsAndUpdateStatus = "ListActiveJobsAndUpdateStatus"
+	CronJobAction_RunNextScheduledJob           = "RunNextScheduledJob"
+	CronJobAction_UpdateCronJobStatus           = "UpdateCronJobStatus"
+	CronJobAction_DeleteAllJobs                 = "DeleteAllJobs"
+	CronJobAction_PruneJobs                     = "PruneJobs"
+)
+
 // CronJobControllerManager declares all the actions needed by the CronJobController.
 type CronJobControllerManager struct {
+	hook   ctrlkit.ActionHook
 	state  CronJobControllerManagerState
 	impl   CronJobControllerManagerImpl
 	logger logr.Logger
 }
 
+// NewAction returns a new action controlled by the manager.
+func (m *CronJobControllerManager) NewAction(description string, f func(context.Context, logr.Logger) (ctrl.Result, error)) ctrlkit.Action {
+	return ctrlkit.NewAction(description, func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = ctrlkit.StartActionRun(ctx)
+		logger := m.logger.WithValues("action", description)
+
+		if m.hook != nil {
+			defer func() { m.hook.PostRun(ctx, logger, description, result, err) }()
+			m.hook.PreRun(ctx, logger, description, nil)
+		}
+
+		return f(ctx, logger)
+	})
+}
+
+// failToGetStates reports the error of getting the states of the action to the hook, and
+// requeues. The hook runs with no states.
+func (m *CronJobControllerManager) failToGetStates(ctx context.Context, logger logr.Logger, action string, err error) (ctrl.Result, error) {
+	result, err := ctrlkit.RequeueIfError(err)
+	if m.hook != nil {
+		m.hook.PreRun(ctx, logger, action, nil)
+		m.hook.PostRun(ctx, logger, action, result, err)
+	}
+	return result, err
+}
+
 // ListActiveJobsAndUpdateStatus generates the action of "ListActiveJobsAndUpdateStatus".
-func (m *CronJobControllerManager) ListActiveJobsAndUpdateStatus() ctrlkit.ReconcileAction {
-	return ctrlkit.WrapAction("ListActiveJobsAndUpdateStatus", func(ctx context.Context) (ctrl.Result, error) {
-		logger := m.logger.WithValues("action", "ListActiveJobsAndUpdateStatus")
+func (m *CronJobControllerManager) ListActiveJobsAndUpdateStatus() ctrlkit.Action {
+	return ctrlkit.NewAction(CronJobAction_ListActiveJobsAndUpdateStatus, func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = ctrlkit.StartActionRun(ctx)
+		logger := m.logger.WithValues("action", CronJobAction_ListActiveJobsAndUpdateStatus)
 
 		// Get states.
 		jobs, err := m.state.GetJobs(ctx)
 		if err != nil {
-			return ctrlkit.RequeueIfError(err)
+			return m.failToGetStates(ctx, logger, CronJobAction_ListActiveJobsAndUpdateStatus, err)
 		}
 
 		// Invoke action.
-		defer m.impl.AfterActionRun("ListActiveJobsAndUpdateStatus", ctx, logger)
-		m.impl.BeforeActionRun("ListActiveJobsAndUpdateStatus", ctx, logger)
+		if m.hook != nil {
+			defer func() { m.hook.PostRun(ctx, logger, CronJobAction_ListActiveJobsAndUpdateStatus, result, err) }()
+			m.hook.PreRun(ctx, logger, CronJobAction_ListActiveJobsAndUpdateStatus, map[string]runtime.Object{
+				"jobs": &batchv1.JobList{Items: jobs},
+			})
+		}
 
 		return m.impl.ListActiveJobsAndUpdateStatus(ctx, logger, jobs)
 	})
 }
 
-// CleanUpOldJobsExceedsHistoryLimits generates the action of "CleanUpOldJobsExceedsHistoryLimits".
-func (m *CronJobControllerManager) CleanUpOldJobsExceedsHistoryLimits() ctrlkit.ReconcileAction {
-	return ctrlkit.WrapAction("CleanUpOldJobsExceedsHistoryLimits", func(ctx context.Context) (ctrl.Result, error) {
-		logger := m.logger.WithValues("action", "CleanUpOldJobsExceedsHistoryLimits")
+// RunNextScheduledJob generates the action of "RunNextScheduledJob".
+func (m *CronJobControllerManager) RunNextScheduledJob() ctrlkit.Action {
+	return ctrlkit.NewAction(CronJobAction_RunNextScheduledJob, func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = ctrlkit.StartActionRun(ctx)
+		logger := m.logger.WithValues("action", CronJobAction_RunNextScheduledJob)
+
+		// Invoke action.
+		if m.hook != nil {
+			defer func() { m.hook.PostRun(ctx, logger, CronJobAction_RunNextScheduledJob, result, err) }()
+			m.hook.PreRun(ctx, logger, CronJobAction_RunNextScheduledJob, nil)
+		}
+
+		return m.impl.RunNextScheduledJob(ctx, logger)
+	})
+}
+
+// UpdateCronJobStatus generates the action of "UpdateCronJobStatus".
+func (m *CronJobControllerManager) UpdateCronJobStatus() ctrlkit.Action {
+	return ctrlkit.NewAction(CronJobAction_UpdateCronJobStatus, func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = ctrlkit.StartActionRun(ctx)
+		logger := m.logger.WithValues("action", CronJobAction_UpdateCronJobStatus)
+
+		// Invoke action.
+		if m.hook != nil {
+			defer func() { m.hook.PostRun(ctx, logger, CronJobAction_UpdateCronJobStatus, result, err) }()
+			m.hook.PreRun(ctx, logger, CronJobAction_UpdateCronJobStatus, nil)
+		}
+
+		return m.impl.UpdateCronJobStatus(ctx, logger)
+	})
+}
+
+// DeleteAllJobs generates the action of "DeleteAllJobs".
+func (m *CronJobControllerManager) DeleteAllJobs() ctrlkit.Action {
+	return ctrlkit.NewAction(CronJobAction_DeleteAllJobs, func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = ctrlkit.StartActionRun(ctx)
+		logger := m.logger.WithValues("action", CronJobAction_DeleteAllJobs)
 
 		// Get states.
 		jobs, err := m.state.GetJobs(ctx)
 		if err != nil {
-			return ctrlkit.RequeueIfError(err)
+			return m.failToGetStates(ctx, logger, CronJobAction_DeleteAllJobs, err)
 		}
 
 		// Invoke action.
-		defer m.impl.AfterActionRun("CleanUpOldJobsExceedsHistoryLimits", ctx, logger)
-		m.impl.BeforeActionRun("CleanUpOldJobsExceedsHistoryLimits", ctx, logger)
+		if m.hook != nil {
+			defer func() { m.hook.PostRun(ctx, logger, CronJobAction_DeleteAllJobs, result, err) }()
+			m.hook.PreRun(ctx, logger, CronJobAction_DeleteAllJobs, map[string]runtime.Object{
+				"jobs": &batchv1.JobList{Items: jobs},
+			})
+		}
 
-		return m.impl.CleanUpOldJobsExceedsHistoryLimits(ctx, logger, jobs)
+		return m.impl.DeleteAllJobs(ctx, logger, jobs)
 	})
 }
 
-// RunNextScheduledJob generates the action of "RunNextScheduledJob".
-func (m *CronJobControllerManager) RunNextScheduledJob() ctrlkit.ReconcileAction {
-	return ctrlkit.WrapAction("RunNextScheduledJob", func(ctx context.Context) (ctrl.Result, error) {
-		logger := m.logger.WithValues("action", "RunNextScheduledJob")
+// PruneJobs generates the action pruning the objects of state "jobs" owned by the target, which
+// are not in the desired names returned by DesiredJobs. Objects are deleted in background by
+// default, and could be only reported in dry-run mode. See ctrlkit.PruneOption.
+func (m *CronJobControllerManager) PruneJobs(opts ...ctrlkit.PruneOption) ctrlkit.Action {
+	return ctrlkit.NewAction(CronJobAction_PruneJobs, func(ctx context.Context) (result ctrl.Result, err error) {
+		ctx = ctrlkit.StartActionRun(ctx)
+		logger := m.logger.WithValues("action", CronJobAction_PruneJobs)
+
+		// Get states.
+		jobs, err := m.state.GetJobs(ctx)
+		if err != nil {
+			return m.failToGetStates(ctx, logger, CronJobAction_PruneJobs, err)
+		}
 
 		// Invoke action.
-		defer m.impl.AfterActionRun("RunNextScheduledJob", ctx, logger)
-		m.impl.BeforeActionRun("RunNextScheduledJob", ctx, logger)
+		if m.hook != nil {
+			defer func() { m.hook.PostRun(ctx, logger, CronJobAction_PruneJobs, result, err) }()
+			m.hook.PreRun(ctx, logger, CronJobAction_PruneJobs, map[string]runtime.Object{
+				"jobs": &batchv1.JobList{Items: jobs},
+			})
+		}
 
-		return m.impl.RunNextScheduledJob(ctx, logger)
+		// Get the desired names.
+		desired, err := m.impl.DesiredJobs(ctx, logger, jobs)
+		if err != nil {
+			return ctrlkit.RequeueIfError(err)
+		}
+
+		// Prune the objects not desired.
+		objs := make([]client.Object, 0, len(jobs))
+		for i := range jobs {
+			objs = append(objs, &jobs[i])
+		}
+		pruned, err := ctrlkit.PruneOwnedObjects(ctx, m.state.Client, m.state.target, objs, desired, opts...)
+		if err != nil {
+			return ctrlkit.RequeueIfError(fmt.Errorf("unable to prune state 'jobs': %w", err))
+		}
+		if len(pruned.Pruned) > 0 {
+			logger.Info("State pruned.", "state", "jobs", "objects", pruned.Pruned, "dryRun", pruned.DryRun)
+		}
+
+		return ctrlkit.NoRequeue()
 	})
 }
 
-// UpdateCronJobStatus generates the action of "UpdateCronJobStatus".
-func (m *CronJobControllerManager) UpdateCronJobStatus() ctrlkit.ReconcileAction {
-	return ctrlkit.WrapAction("UpdateCronJobStatus", func(ctx context.Context) (ctrl.Result, error) {
-		logger := m.logger.WithValues("action", "UpdateCronJobStatus")
+// CronJobFinalizer is the finalizer of CronJobControllerManager.
+const CronJobFinalizer = "demo.ctrlkit.io/cleanup"
 
-		// Invoke action.
-		defer m.impl.AfterActionRun("UpdateCronJobStatus", ctx, logger)
-		m.impl.BeforeActionRun("UpdateCronJobStatus", ctx, logger)
+// Finalizer generates the action managing the finalizer "demo.ctrlkit.io/cleanup".
+// When the target isn't being deleted, it adds the finalizer if missing. Otherwise, it runs
+// the clean up actions sequentially, removes the finalizer after they succeed, and exits.
+func (m *CronJobControllerManager) Finalizer() ctrlkit.Action {
+	return ctrlkit.Finalizer(m.state.Client, m.state.target, CronJobFinalizer, ctrlkit.Sequential(
+		m.DeleteAllJobs(),
+	))
+}
 
-		return m.impl.UpdateCronJobStatus(ctx, logger)
-	})
+// Workflow assembles the actions of CronJobControllerManager into the reconcile workflow declared.
+// Reconcile the CronJob, and always update the status at last.
+func (m *CronJobControllerManager) Workflow() ctrlkit.ReconcileAction {
+	return ctrlkit.Defer(
+		ctrlkit.Sequential(
+			m.Finalizer(),
+			ctrlkit.Join(
+				m.ListActiveJobsAndUpdateStatus(),
+				m.PruneJobs(),
+				ctrlkit.Unless(
+					ctrlkit.Named("Suspended", func(ctx context.Context) (bool, error) {
+						return m.impl.Suspended(ctx, m.logger.WithValues("predicate", "Suspended"))
+					}),
+					m.RunNextScheduledJob(),
+				),
+			),
+		),
+		m.UpdateCronJobStatus(),
+	)
+}
+
+// CronJobControllerManagerFieldIndexes returns the field indexes used by the states of CronJobControllerManager.
+func CronJobControllerManagerFieldIndexes() []ctrlkit.FieldIndex {
+	return []ctrlkit.FieldIndex{
+		{Object: &batchv1.Job{}, Field: ".metadata.controller", Extractor: ctrlkit.IndexByController("demo/v1", "CronJob")},
+	}
+}
+
+// RegisterCronJobControllerManagerIndexes registers the field indexes used by the states of CronJobControllerManager to the
+// indexer, e.g., mgr.GetFieldIndexer().
+func RegisterCronJobControllerManagerIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	return ctrlkit.RegisterFieldIndexes(ctx, indexer, CronJobControllerManagerFieldIndexes()...)
+}
+
+// WithCronJobControllerManagerIndexes wraps the client, e.g., a fake one in tests, to serve the field
+// selectors of the states of CronJobControllerManager with the same indexes.
+func WithCronJobControllerManagerIndexes(c client.Client) client.Client {
+	return ctrlkit.WithFieldIndexes(c, CronJobControllerManagerFieldIndexes()...)
+}
+
+// SetupCronJobControllerManagerWithManager sets up the controller of CronJob with the manager, reconciled by r.
+// It watches CronJob, the owned states, and the states selected by the labels of the target name.
+// The field indexes used by the states are registered as well.
+func SetupCronJobControllerManagerWithManager(mgr ctrl.Manager, r reconcile.Reconciler) error {
+	if err := RegisterCronJobControllerManagerIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.CronJob{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
+
+type CronJobControllerManagerOption func(*CronJobControllerManager)
+
+func CronJobControllerManager_WithActionHook(hook ctrlkit.ActionHook) CronJobControllerManagerOption {
+	return func(m *CronJobControllerManager) {
+		m.hook = hook
+	}
 }
 
 // NewCronJobControllerManager returns a new CronJobControllerManager with given state and implementation.
-func NewCronJobControllerManager(state CronJobControllerManagerState, impl CronJobControllerManagerImpl, logger logr.Logger) CronJobControllerManager {
-	return CronJobControllerManager{
+func NewCronJobControllerManager(state CronJobControllerManagerState, impl CronJobControllerManagerImpl, logger logr.Logger, opts ...CronJobControllerManagerOption) CronJobControllerManager {
+	m := CronJobControllerManager{
 		state:  state,
 		impl:   impl,
 		logger: logger,
 	}
+
+	for _, opt := range opts {
+		opt(&m)
+	}
+
+	return m
 }
@@ -0,0 +1,177 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+// Code generated by ctrlkit. DO NOT EDIT.
+
+package manager
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// FakeCronJobControllerManagerImpl is a fake CronJobControllerManagerImpl for tests.
+// Every method records the call with the states, and then calls the func field if set, or
+// returns the canned results otherwise.
+type FakeCronJobControllerManagerImpl struct {
+	mu sync.Mutex
+
+	// ListActiveJobsAndUpdateStatusFunc is called by ListActiveJobsAndUpdateStatus if set.
+	ListActiveJobsAndUpdateStatusFunc func(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error)
+	// ListActiveJobsAndUpdateStatusResult and ListActiveJobsAndUpdateStatusErr are returned by ListActiveJobsAndUpdateStatus if ListActiveJobsAndUpdateStatusFunc isn't set.
+	ListActiveJobsAndUpdateStatusResult ctrl.Result
+	ListActiveJobsAndUpdateStatusErr    error
+	// ListActiveJobsAndUpdateStatusCalls are the recorded calls of ListActiveJobsAndUpdateStatus.
+	ListActiveJobsAndUpdateStatusCalls []FakeCronJobControllerManagerImpl_ListActiveJobsAndUpdateStatusCall
+
+	// RunNextScheduledJobFunc is called by RunNextScheduledJob if set.
+	RunNextScheduledJobFunc func(ctx context.Context, logger logr.Logger) (ctrl.Result, error)
+	// RunNextScheduledJobResult and RunNextScheduledJobErr are returned by RunNextScheduledJob if RunNextScheduledJobFunc isn't set.
+	RunNextScheduledJobResult ctrl.Result
+	RunNextScheduledJobErr    error
+	// RunNextScheduledJobCalls are the recorded calls of RunNextScheduledJob.
+	RunNextScheduledJobCalls []FakeCronJobControllerManagerImpl_RunNextScheduledJobCall
+
+	// UpdateCronJobStatusFunc is called by UpdateCronJobStatus if set.
+	UpdateCronJobStatusFunc func(ctx context.Context, logger logr.Logger) (ctrl.Result, error)
+	// UpdateCronJobStatusResult and UpdateCronJobStatusErr are returned by UpdateCronJobStatus if UpdateCronJobStatusFunc isn't set.
+	UpdateCronJobStatusResult ctrl.Result
+	UpdateCronJobStatusErr    error
+	// UpdateCronJobStatusCalls are the recorded calls of UpdateCronJobStatus.
+	UpdateCronJobStatusCalls []FakeCronJobControllerManagerImpl_UpdateCronJobStatusCall
+
+	// DeleteAllJobsFunc is called by DeleteAllJobs if set.
+	DeleteAllJobsFunc func(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error)
+	// DeleteAllJobsResult and DeleteAllJobsErr are returned by DeleteAllJobs if DeleteAllJobsFunc isn't set.
+	DeleteAllJobsResult ctrl.Result
+	DeleteAllJobsErr    error
+	// DeleteAllJobsCalls are the recorded calls of DeleteAllJobs.
+	DeleteAllJobsCalls []FakeCronJobControllerManagerImpl_DeleteAllJobsCall
+
+	// DesiredJobsFunc is called by DesiredJobs if set.
+	DesiredJobsFunc func(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) ([]string, error)
+	// DesiredJobsResult and DesiredJobsErr are returned by DesiredJobs if DesiredJobsFunc isn't set.
+	DesiredJobsResult []string
+	DesiredJobsErr    error
+	// DesiredJobsCalls are the recorded calls of DesiredJobs.
+	DesiredJobsCalls []FakeCronJobControllerManagerImpl_DesiredJobsCall
+
+	// SuspendedFunc is called by Suspended if set.
+	SuspendedFunc func(ctx context.Context, logger logr.Logger) (bool, error)
+	// SuspendedResult and SuspendedErr are returned by Suspended if SuspendedFunc isn't set.
+	SuspendedResult bool
+	SuspendedErr    error
+	// SuspendedCalls are the recorded calls of Suspended.
+	SuspendedCalls []FakeCronJobControllerManagerImpl_SuspendedCall
+}
+
+var _ CronJobControllerManagerImpl = &FakeCronJobControllerManagerImpl{}
+
+// FakeCronJobControllerManagerImpl_ListActiveJobsAndUpdateStatusCall is a recorded call of ListActiveJobsAndUpdateStatus.
+type FakeCronJobControllerManagerImpl_ListActiveJobsAndUpdateStatusCall struct {
+	Jobs []batchv1.Job
+}
+
+// ListActiveJobsAndUpdateStatus records the call, and calls ListActiveJobsAndUpdateStatusFunc if set, or returns the canned results otherwise.
+func (f *FakeCronJobControllerManagerImpl) ListActiveJobsAndUpdateStatus(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error) {
+	f.mu.Lock()
+	f.ListActiveJobsAndUpdateStatusCalls = append(f.ListActiveJobsAndUpdateStatusCalls, FakeCronJobControllerManagerImpl_ListActiveJobsAndUpdateStatusCall{Jobs: jobs})
+	fn, result, err := f.ListActiveJobsAndUpdateStatusFunc, f.ListActiveJobsAndUpdateStatusResult, f.ListActiveJobsAndUpdateStatusErr
+	f.mu.Unlock()
+
+	if fn != nil {
+		return fn(ctx, logger, jobs)
+	}
+	return result, err
+}
+
+// FakeCronJobControllerManagerImpl_RunNextScheduledJobCall is a recorded call of RunNextScheduledJob.
+type FakeCronJobControllerManagerImpl_RunNextScheduledJobCall struct {
+}
+
+// RunNextScheduledJob records the call, and calls RunNextScheduledJobFunc if set, or returns the canned results otherwise.
+func (f *FakeCronJobControllerManagerImpl) RunNextScheduledJob(ctx context.Context, logger logr.Logger) (ctrl.Result, error) {
+	f.mu.Lock()
+	f.RunNextScheduledJobCalls = append(f.RunNextScheduledJobCalls, FakeCronJobControllerManagerImpl_RunNextScheduledJobCall{})
+	fn, result, err := f.RunNextScheduledJobFunc, f.RunNextScheduledJobResult, f.RunNextScheduledJobErr
+	f.mu.Unlock()
+
+	if fn != nil {
+		return fn(ctx, logger)
+	}
+	return result, err
+}
+
+// FakeCronJobControllerManagerImpl_UpdateCronJobStatusCall is a recorded call of UpdateCronJobStatus.
+type FakeCronJobControllerManagerImpl_UpdateCronJobStatusCall struct {
+}
+
+// UpdateCronJobStatus records the call, and calls UpdateCronJobStatusFunc if set, or returns the canned results otherwise.
+func (f *FakeCronJobControllerManagerImpl) UpdateCronJobStatus(ctx context.Context, logger logr.Logger) (ctrl.Result, error) {
+	f.mu.Lock()
+	f.UpdateCronJobStatusCalls = append(f.UpdateCronJobStatusCalls, FakeCronJobControllerManagerImpl_UpdateCronJobStatusCall{})
+	fn, result, err := f.UpdateCronJobStatusFunc, f.UpdateCronJobStatusResult, f.UpdateCronJobStatusErr
+	f.mu.Unlock()
+
+	if fn != nil {
+		return fn(ctx, logger)
+	}
+	return result, err
+}
+
+// FakeCronJobControllerManagerImpl_DeleteAllJobsCall is a recorded call of DeleteAllJobs.
+type FakeCronJobControllerManagerImpl_DeleteAllJobsCall struct {
+	Jobs []batchv1.Job
+}
+
+// DeleteAllJobs records the call, and calls DeleteAllJobsFunc if set, or returns the canned results otherwise.
+func (f *FakeCronJobControllerManagerImpl) DeleteAllJobs(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error) {
+	f.mu.Lock()
+	f.DeleteAllJobsCalls = append(f.DeleteAllJobsCalls, FakeCronJobControllerManagerImpl_DeleteAllJobsCall{Jobs: jobs})
+	fn, result, err := f.DeleteAllJobsFunc, f.DeleteAllJobsResult, f.DeleteAllJobsErr
+	f.mu.Unlock()
+
+	if fn != nil {
+		return fn(ctx, logger, jobs)
+	}
+	return result, err
+}
+
+// FakeCronJobControllerManagerImpl_DesiredJobsCall is a recorded call of DesiredJobs.
+type FakeCronJobControllerManagerImpl_DesiredJobsCall struct {
+	Jobs []batchv1.Job
+}
+
+// DesiredJobs records the call, and calls DesiredJobsFunc if set, or returns the canned results otherwise.
+func (f *FakeCronJobControllerManagerImpl) DesiredJobs(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) ([]string, error) {
+	f.mu.Lock()
+	f.DesiredJobsCalls = append(f.DesiredJobsCalls, FakeCronJobControllerManagerImpl_DesiredJobsCall{Jobs: jobs})
+	fn, result, err := f.DesiredJobsFunc, f.DesiredJobsResult, f.DesiredJobsErr
+	f.mu.Unlock()
+
+	if fn != nil {
+		return fn(ctx, logger, jobs)
+	}
+	return result, err
+}
+
+// FakeCronJobControllerManagerImpl_SuspendedCall is a recorded call of Suspended.
+type FakeCronJobControllerManagerImpl_SuspendedCall struct {
+}
+
+// Suspended records the call, and calls SuspendedFunc if set, or returns the canned results otherwise.
+func (f *FakeCronJobControllerManagerImpl) Suspended(ctx context.Context, logger logr.Logger) (bool, error) {
+	f.mu.Lock()
+	f.SuspendedCalls = append(f.SuspendedCalls, FakeCronJobControllerManagerImpl_SuspendedCall{})
+	fn, result, err := f.SuspendedFunc, f.SuspendedResult, f.SuspendedErr
+	f.mu.Unlock()
+
+	if fn != nil {
+		return fn(ctx, logger)
+	}
+	return result, err
+}
@@ -3,11 +3,13 @@ package manager
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/arkbriar/ctrlkit/pkg/ctrlkit"
 	"github.com/go-logr/logr"
 	batchv1 "k8s.io/api/batch/v1"
-	"k8s.io/apimachinery/pkg/api/equality"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -15,71 +17,105 @@ import (
 	apiv1 "demo/api/v1"
 )
 
-type cronJobManager struct {
-	cronJob        *apiv1.CronJob
-	originalStatus *apiv1.CronJobStatus
+type cronJobControllerManagerImpl struct {
+	client     client.Client
+	cronJob    *apiv1.CronJob
+	conditions *ctrlkit.ConditionManager
 }
 
-func newCronJobManager(cronJob *apiv1.CronJob) cronJobManager {
-	return cronJobManager{
-		cronJob:        cronJob,
-		originalStatus: cronJob.Status.DeepCopy(),
+func (mgr *cronJobControllerManagerImpl) UpdateCronJobStatus(ctx context.Context, logger logr.Logger) (reconcile.Result, error) {
+	// Conditions are set from the outcomes of the actions, and the status is updated only if changed.
+	if result, err := mgr.conditions.UpdateStatus().Run(ctx); ctrlkit.NeedsRequeue(result, err) {
+		return result, err
 	}
+	logger.Info("Status is up to date.")
+	return ctrlkit.NoRequeue()
 }
 
-func (m *cronJobManager) CronJob() *apiv1.CronJob {
-	return m.cronJob
+func (mgr *cronJobControllerManagerImpl) ListActiveJobsAndUpdateStatus(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error) {
+	// TODO
+	logger.Info("TODO")
+	return ctrlkit.NoRequeue()
 }
 
-// IsStatusUpdated tells if the status of the CronJob managed by the manager has been changed.
-func (m *cronJobManager) IsStatusUpdated() bool {
-	return !equality.Semantic.DeepEqual(&m.cronJob.Status, m.originalStatus)
+// isJobFinished tells if the job has completed or failed, and which one.
+func isJobFinished(job *batchv1.Job) (bool, batchv1.JobConditionType) {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return true, c.Type
+		}
+	}
+	return false, ""
 }
 
-type cronJobControllerManagerImpl struct {
-	ctrlkit.EmptyCrontollerManagerActionLifeCycleHook
-
-	client  client.Client
-	cronJob cronJobManager
+// newestJobNames returns the names of the newest jobs up to limit.
+func newestJobNames(jobs []*batchv1.Job, limit int32) []string {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[j].CreationTimestamp.Before(&jobs[i].CreationTimestamp)
+	})
+	if int32(len(jobs)) > limit {
+		jobs = jobs[:limit]
+	}
+	names := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		names = append(names, job.Name)
+	}
+	return names
 }
 
-func (mgr *cronJobControllerManagerImpl) UpdateCronJobStatus(ctx context.Context, logger logr.Logger) (reconcile.Result, error) {
-	// Guard.
-	if !mgr.cronJob.IsStatusUpdated() {
-		logger.Info("Status hasn't been changed, skip update.")
-		return ctrlkit.NoRequeue()
+func (mgr *cronJobControllerManagerImpl) DesiredJobs(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) ([]string, error) {
+	successfulLimit, failedLimit := int32(3), int32(1)
+	if mgr.cronJob.Spec.SuccessfulJobsHistoryLimit != nil {
+		successfulLimit = *mgr.cronJob.Spec.SuccessfulJobsHistoryLimit
+	}
+	if mgr.cronJob.Spec.FailedJobsHistoryLimit != nil {
+		failedLimit = *mgr.cronJob.Spec.FailedJobsHistoryLimit
 	}
 
-	// Do update.
-	logger.Info("Status has been changed, update...")
-	if err := mgr.client.Status().Update(ctx, mgr.cronJob.CronJob()); err != nil {
-		return ctrlkit.RequeueIfError(fmt.Errorf("unable to update status: %w", err))
+	// Keep all the active jobs, and the newest finished ones within the history limits.
+	var desired []string
+	var successful, failed []*batchv1.Job
+	for i := range jobs {
+		job := &jobs[i]
+		switch finished, condType := isJobFinished(job); {
+		case !finished:
+			desired = append(desired, job.Name)
+		case condType == batchv1.JobComplete:
+			successful = append(successful, job)
+		default:
+			failed = append(failed, job)
+		}
 	}
-	logger.Info("Status updated!")
-	return ctrlkit.NoRequeue()
+	desired = append(desired, newestJobNames(successful, successfulLimit)...)
+	desired = append(desired, newestJobNames(failed, failedLimit)...)
+
+	return desired, nil
 }
 
-func (mgr *cronJobControllerManagerImpl) ListActiveJobsAndUpdateStatus(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error) {
+func (mgr *cronJobControllerManagerImpl) RunNextScheduledJob(ctx context.Context, logger logr.Logger) (ctrl.Result, error) {
 	// TODO
 	logger.Info("TODO")
 	return ctrlkit.NoRequeue()
 }
 
-func (mgr *cronJobControllerManagerImpl) CleanUpOldJobsExceedsHistoryLimits(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error) {
-	// TODO
-	logger.Info("TODO")
-	return ctrlkit.NoRequeue()
+func (mgr *cronJobControllerManagerImpl) Suspended(ctx context.Context, logger logr.Logger) (bool, error) {
+	return mgr.cronJob.Spec.Suspend != nil && *mgr.cronJob.Spec.Suspend, nil
 }
 
-func (mgr *cronJobControllerManagerImpl) RunNextScheduledJob(ctx context.Context, logger logr.Logger) (ctrl.Result, error) {
-	// TODO
-	logger.Info("TODO")
+func (mgr *cronJobControllerManagerImpl) DeleteAllJobs(ctx context.Context, logger logr.Logger, jobs []batchv1.Job) (ctrl.Result, error) {
+	for i := range jobs {
+		if err := mgr.client.Delete(ctx, &jobs[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return ctrlkit.RequeueIfError(fmt.Errorf("unable to delete job %s: %w", jobs[i].Name, err))
+		}
+	}
+	logger.Info("All jobs deleted.", "count", len(jobs))
 	return ctrlkit.NoRequeue()
 }
 
-func NewCronJobControllerManagerImpl(client client.Client, target *apiv1.CronJob) CronJobControllerManagerImpl {
+func NewCronJobControllerManagerImpl(client client.Client, target *apiv1.CronJob, conditions *ctrlkit.ConditionManager) CronJobControllerManagerImpl {
 	return &cronJobControllerManagerImpl{
-		client:  client,
-		cronJob: newCronJobManager(target),
+		client:     client,
+		cronJob:    target,
+		conditions: conditions,
 	}
 }
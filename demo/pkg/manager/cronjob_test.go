@@ -0,0 +1,292 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arkbriar/ctrlkit/pkg/ctrlkit"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "demo/api/v1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = apiv1.AddToScheme(scheme)
+}
+
+type recordActionHook struct {
+	preRuns  []string
+	postRuns []string
+	states   map[string]map[string]runtime.Object
+	errs     map[string]error
+}
+
+func (h *recordActionHook) PreRun(ctx context.Context, logger logr.Logger, action string, states map[string]runtime.Object) {
+	h.preRuns = append(h.preRuns, action)
+	h.states[action] = states
+}
+
+func (h *recordActionHook) PostRun(ctx context.Context, logger logr.Logger, action string, result ctrl.Result, err error) {
+	h.postRuns = append(h.postRuns, action)
+	if h.errs != nil {
+		h.errs[action] = err
+	}
+}
+
+// failingListClient fails every List with err.
+type failingListClient struct {
+	client.Client
+	err error
+}
+
+func (c *failingListClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.err
+}
+
+func Test_CronJobControllerManager_HookOnStateError(t *testing.T) {
+	cronJob := &apiv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+	}
+	listErr := errors.New("list failed")
+	c := &failingListClient{
+		Client: WithCronJobControllerManagerIndexes(fake.NewClientBuilder().WithScheme(scheme).WithObjects(cronJob).Build()),
+		err:    listErr,
+	}
+
+	hook := &recordActionHook{
+		states: make(map[string]map[string]runtime.Object),
+		errs:   make(map[string]error),
+	}
+	state := NewCronJobControllerManagerState(c, cronJob.DeepCopy())
+	target := cronJob.DeepCopy()
+	impl := NewCronJobControllerManagerImpl(c, target, ctrlkit.NewConditionManager(c, target, &target.Status.Conditions))
+	mgr := NewCronJobControllerManager(state, impl, logr.Discard(), CronJobControllerManager_WithActionHook(hook))
+
+	for _, act := range []ctrlkit.Action{
+		mgr.ListActiveJobsAndUpdateStatus(),
+		mgr.DeleteAllJobs(),
+		mgr.PruneJobs(),
+	} {
+		if _, err := act.Run(context.Background()); !errors.Is(err, listErr) {
+			t.Fatalf("unexpected error of %s: %v", act.Description(), err)
+		}
+	}
+
+	for _, action := range []string{
+		CronJobAction_ListActiveJobsAndUpdateStatus,
+		CronJobAction_DeleteAllJobs,
+		CronJobAction_PruneJobs,
+	} {
+		if !errors.Is(hook.errs[action], listErr) {
+			t.Fatalf("error of %s not reported to hook: %v", action, hook.errs[action])
+		}
+	}
+	if len(hook.preRuns) != 3 || len(hook.postRuns) != 3 {
+		t.Fatalf("hooks not invoked as expected: pre = %v, post = %v", hook.preRuns, hook.postRuns)
+	}
+}
+
+func Test_CronJobControllerManager_WithActionHook(t *testing.T) {
+	cronJob := &apiv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+	}
+	client := WithCronJobControllerManagerIndexes(fake.NewClientBuilder().WithScheme(scheme).WithObjects(cronJob).Build())
+
+	hook := &recordActionHook{states: make(map[string]map[string]runtime.Object)}
+	registry := prometheus.NewRegistry()
+	metricsHook, err := ctrlkit.NewMetricsHook(registry, "CronJobControllerManager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := NewCronJobControllerManagerState(client, cronJob.DeepCopy())
+	target := cronJob.DeepCopy()
+	impl := NewCronJobControllerManagerImpl(client, target, ctrlkit.NewConditionManager(client, target, &target.Status.Conditions))
+	mgr := NewCronJobControllerManager(state, impl, logr.Discard(),
+		CronJobControllerManager_WithActionHook(ctrlkit.ChainActionHooks(hook, metricsHook)))
+
+	_, err = ctrlkit.JoinOrdered(
+		mgr.ListActiveJobsAndUpdateStatus(),
+		mgr.RunNextScheduledJob(),
+	).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hook.preRuns) != 2 || len(hook.postRuns) != 2 {
+		t.Fatalf("hooks not invoked as expected: pre = %v, post = %v", hook.preRuns, hook.postRuns)
+	}
+	if _, ok := hook.states[CronJobAction_ListActiveJobsAndUpdateStatus]["jobs"].(*batchv1.JobList); !ok {
+		t.Fatal("states of ListActiveJobsAndUpdateStatus not passed to hook")
+	}
+	if hook.states[CronJobAction_RunNextScheduledJob] != nil {
+		t.Fatal("states of RunNextScheduledJob should be nil")
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := make(map[string]*dto.Metric)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			key := family.GetName()
+			for _, l := range m.GetLabel() {
+				key += "," + l.GetName() + "=" + l.GetValue()
+			}
+			metrics[key] = m
+		}
+	}
+	for _, action := range []string{CronJobAction_ListActiveJobsAndUpdateStatus, CronJobAction_RunNextScheduledJob} {
+		labels := ",action=" + action + ",manager=CronJobControllerManager"
+		if m := metrics["ctrlkit_action_outcomes_total"+labels+",outcome=success"]; m.GetCounter().GetValue() != 1 {
+			t.Fatalf("outcome of %s is not recorded: %v", action, m)
+		}
+		if m := metrics["ctrlkit_action_duration_seconds"+labels]; m.GetHistogram().GetSampleCount() != 1 {
+			t.Fatalf("duration of %s is not recorded: %v", action, m)
+		}
+		if m := metrics["ctrlkit_action_in_flight"+labels]; m.GetGauge().GetValue() != 0 {
+			t.Fatalf("in-flight number of %s is not correct: %v", action, m)
+		}
+	}
+}
+
+func newTestJob(cronJob *apiv1.CronJob, name string, created time.Time, finished batchv1.JobConditionType) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         cronJob.Namespace,
+			Labels:            map[string]string{"cronjob": cronJob.Name},
+			CreationTimestamp: metav1.NewTime(created),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, apiv1.GroupVersion.WithKind("CronJob")),
+			},
+		},
+	}
+	if finished != "" {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: finished, Status: corev1.ConditionTrue}}
+	}
+	return job
+}
+
+func Test_CronJobControllerManager_PruneJobs(t *testing.T) {
+	limit := int32(1)
+	cronJob := &apiv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+			UID:       "example-uid",
+		},
+		Spec: apiv1.CronJobSpec{
+			SuccessfulJobsHistoryLimit: &limit,
+		},
+	}
+	now := time.Now()
+	client := WithCronJobControllerManagerIndexes(fake.NewClientBuilder().WithScheme(scheme).WithObjects(cronJob,
+		newTestJob(cronJob, "old", now.Add(-2*time.Hour), batchv1.JobComplete),
+		newTestJob(cronJob, "new", now.Add(-time.Hour), batchv1.JobComplete),
+		newTestJob(cronJob, "active", now.Add(-3*time.Hour), ""),
+	).Build())
+
+	state := NewCronJobControllerManagerState(client, cronJob.DeepCopy())
+	target := cronJob.DeepCopy()
+	impl := NewCronJobControllerManagerImpl(client, target, ctrlkit.NewConditionManager(client, target, &target.Status.Conditions))
+	mgr := NewCronJobControllerManager(state, impl, logr.Discard())
+
+	countJobs := func() int {
+		var jobs batchv1.JobList
+		if err := client.List(context.Background(), &jobs); err != nil {
+			t.Fatal(err)
+		}
+		return len(jobs.Items)
+	}
+
+	if _, err := mgr.PruneJobs(ctrlkit.PruneDryRun(true)).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if countJobs() != 3 {
+		t.Fatal("jobs should not be pruned in dry-run mode")
+	}
+
+	if _, err := mgr.PruneJobs().Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if countJobs() != 2 {
+		t.Fatal("old job is not pruned")
+	}
+	var job batchv1.Job
+	if err := client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "old"}, &job); !apierrors.IsNotFound(err) {
+		t.Fatal("old job should be pruned")
+	}
+}
+
+func Test_CronJobControllerManager_FakeImpl(t *testing.T) {
+	cronJob := &apiv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+			UID:       "example-uid",
+		},
+	}
+	now := time.Now()
+	orphan := newTestJob(cronJob, "orphan", now, batchv1.JobComplete)
+	orphan.OwnerReferences = nil
+	client := WithCronJobControllerManagerIndexes(fake.NewClientBuilder().WithScheme(scheme).WithObjects(cronJob,
+		newTestJob(cronJob, "old", now.Add(-2*time.Hour), batchv1.JobComplete),
+		newTestJob(cronJob, "new", now.Add(-time.Hour), batchv1.JobComplete),
+		orphan,
+	).Build())
+
+	impl := &FakeCronJobControllerManagerImpl{
+		DesiredJobsResult: []string{"new"},
+	}
+	mgr := NewCronJobControllerManager(NewCronJobControllerManagerState(client, cronJob.DeepCopy()), impl, logr.Discard())
+
+	if _, err := mgr.PruneJobs().Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(impl.DesiredJobsCalls) != 1 {
+		t.Fatalf("DesiredJobs should be called once, but called %d times", len(impl.DesiredJobsCalls))
+	}
+	names := make([]string, 0, len(impl.DesiredJobsCalls[0].Jobs))
+	for _, job := range impl.DesiredJobsCalls[0].Jobs {
+		names = append(names, job.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "new,old" {
+		t.Fatalf("DesiredJobs should receive exactly the owned jobs, but got %v", names)
+	}
+
+	var job batchv1.Job
+	if err := client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "old"}, &job); !apierrors.IsNotFound(err) {
+		t.Fatal("old job should be pruned")
+	}
+	if err := client.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "orphan"}, &job); err != nil {
+		t.Fatal("orphan job should not be pruned")
+	}
+}
@@ -0,0 +1,3 @@
+package manager
+
+//go:generate go run github.com/arkbriar/ctrlkit/ctrlkit/cmd/ctrlkit-gen -fake .